@@ -3,81 +3,99 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/ejecs/ejecs/internal/ast"
 	"github.com/ejecs/ejecs/internal/generator"
 	"github.com/ejecs/ejecs/internal/parser"
+	"github.com/ejecs/ejecs/internal/robloxapi"
+	"github.com/ejecs/ejecs/internal/schemadiff"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		runDiff(os.Args[2:])
+		return
+	}
+
 	// Define flags
-	inputFile := flag.String("input", "", "Input EJECS file")
-	outputFile := flag.String("output", "", "Output file for generated Luau code")
-	// library := flag.String("library", "jecs", "Target ECS library (ecr or jecs)") // Removed library flag
+	inputFile := flag.String("input", "", "Input EJECS file, or - for stdin")
+	outputFile := flag.String("output", "", "Output file for generated Luau code, or - for stdout")
+	apiFile := flag.String("api", "", "Roblox API dump to use instead of the bundled type database")
+	library := flag.String("library", generator.LibraryJECS, "ECS library the system loops query (ecr or jecs)")
+	runtime := flag.Bool("runtime", false, "Also write "+generator.RuntimeFile+" next to the output")
 	flag.Parse()
 
 	if *inputFile == "" || *outputFile == "" {
 		// fmt.Println("Usage: ejecs -input <input.jecs> -output <output.luau> -library <ecr|jecs>") // Old usage message
-		fmt.Println("Usage: ejecs -input <input.jecs> -output <output.luau>")
+		fmt.Fprintln(os.Stderr, "Usage: ejecs -input <input.jecs> -output <output.luau> [-library <ecr|jecs>] [-api <API-Dump.json>] [-runtime]")
+		fmt.Fprintln(os.Stderr, "       ejecs diff <old.ejecs> <new.ejecs>")
 		os.Exit(1)
 	}
 
-	// Validate library - Removed validation
-	// if *library != "ecr" && *library != "jecs" {
-	// 	fmt.Printf("Error: library must be either 'ecr' or 'jecs', got '%s'\n", *library)
-	// 	os.Exit(1)
-	// }
-
-	// Read input file
-	content, err := os.ReadFile(*inputFile)
-	if err != nil {
-		fmt.Printf("Error reading input file: %v\n", err)
+	g := generator.New() // Simplified generator instantiation
+	if err := g.SetLibrary(*library); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	// Parse the content
-	p := parser.New(string(content))
-	ast, err := p.ParseProgram()
-	if err != nil {
-		// Check for parser errors
-		if p.Errors() != nil && len(p.Errors()) > 0 {
-			fmt.Println("Parse errors:")
-			for _, msg := range p.Errors() {
-				fmt.Println("-", msg)
-			}
-		} else {
-			// Print general parse error if no specific messages
-			fmt.Printf("Parse error: %v\n", err)
+	if *apiFile != "" {
+		db, err := robloxapi.LoadFile(*apiFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading API dump: %v\n", err)
+			os.Exit(1)
 		}
-		os.Exit(1)
+		robloxapi.Use(db)
 	}
 
+	program := parseFile(*inputFile)
+
 	// Generate code
-	// g := generator.New(generator.Config{Library: *library}) // Old generator instantiation
-	g := generator.New() // Simplified generator instantiation
-	code, err := g.Generate(ast)
+	code, err := g.Generate(program)
 	if err != nil {
-		fmt.Printf("Generation error: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Generation error: %v\n", err)
 		os.Exit(1)
 	}
+	for _, warning := range g.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	if *outputFile == "-" {
+		if *runtime {
+			fmt.Fprintln(os.Stderr, "Error: -runtime needs an output file to write the runtime next to")
+			os.Exit(1)
+		}
+		fmt.Print(code)
+		return
+	}
 
 	// Ensure output directory exists
 	dir := filepath.Dir(*outputFile)
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		fmt.Printf("Error creating output directory: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error creating output directory: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Write output file
 	if err := os.WriteFile(*outputFile, []byte(code), 0644); err != nil {
-		fmt.Printf("Error writing output file: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error writing output file: %v\n", err)
 		os.Exit(1)
 	}
 
 	// fmt.Printf("Successfully generated %s for %s library\n", *outputFile, *library) // Old success message
 	fmt.Printf("Successfully generated %s\n", *outputFile)
+
+	if *runtime {
+		runtimeFile := filepath.Join(dir, generator.RuntimeFile)
+		if err := os.WriteFile(runtimeFile, []byte(generator.Runtime), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing runtime: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Successfully generated %s\n", runtimeFile)
+	}
 }
 
 // AST types
@@ -248,3 +266,66 @@ func generateRelationship(code *strings.Builder, rel *Relationship) error {
 	// TODO: Implement relationship code generation
 	return nil
 }
+
+// parseFile reads and parses an EJECS file, or stdin when path is -, exiting
+// on errors
+func parseFile(path string) *ast.Program {
+	// Read input file
+	var content []byte
+	var err error
+	if path == "-" {
+		content, err = io.ReadAll(os.Stdin)
+		path = "stdin"
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading input file: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Parse the content
+	p := parser.New(string(content))
+	program, err := p.ParseProgram()
+	if err != nil {
+		// Check for parser errors
+		if p.Errors() != nil && len(p.Errors()) > 0 {
+			fmt.Fprintf(os.Stderr, "Parse errors in %s:\n", path)
+			for _, msg := range p.Errors() {
+				fmt.Fprintln(os.Stderr, "-", msg)
+			}
+		} else {
+			// Print general parse error if no specific messages
+			fmt.Fprintf(os.Stderr, "Parse error in %s: %v\n", path, err)
+		}
+		os.Exit(1)
+	}
+	return program
+}
+
+// runDiff implements `ejecs diff old.ejecs new.ejecs`. It exits with status 1
+// when any change would break data saved with the old schema.
+func runDiff(args []string) {
+	if len(args) != 2 {
+		fmt.Fprintln(os.Stderr, "Usage: ejecs diff <old.ejecs> <new.ejecs>")
+		os.Exit(1)
+	}
+
+	changes := schemadiff.Compare(parseFile(args[0]), parseFile(args[1]))
+	if len(changes) == 0 {
+		fmt.Println("No schema changes")
+		return
+	}
+
+	breaking := 0
+	for _, change := range changes {
+		fmt.Println(change)
+		if change.Breaking {
+			breaking++
+		}
+	}
+	fmt.Printf("\n%d breaking, %d compatible change(s)\n", breaking, len(changes)-breaking)
+	if breaking > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain runs main instead of the tests when EJECS_ARGS is set, so the tests
+// can start the test binary as the CLI and look at what it writes
+func TestMain(m *testing.M) {
+	if args, ok := os.LookupEnv("EJECS_ARGS"); ok {
+		os.Args = append([]string{"ejecs"}, strings.Fields(args)...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runCLI runs the CLI with args and input on stdin
+func runCLI(t *testing.T, input string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "EJECS_ARGS="+strings.Join(args, " "))
+	cmd.Stdin = strings.NewReader(input)
+	var out, errOut bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &errOut
+	err = cmd.Run()
+	return out.String(), errOut.String(), err
+}
+
+func TestStdoutOutput(t *testing.T) {
+	input := `
+	component Inventory {
+		table<string, number> items;
+		number gold = 0;
+	}
+	`
+
+	stdout, stderr, err := runCLI(t, input, "-input", "-", "-output", "-")
+	assert.NoError(t, err, stderr)
+	assert.Empty(t, stderr)
+	assert.True(t, strings.HasPrefix(stdout, "-- Generated by EJECS"), "stdout should hold only the module, got:\n%s", stdout)
+	assert.Contains(t, stdout, "items: { [string]: number }")
+	assert.NotContains(t, stdout, "Successfully generated")
+}
+
+func TestStdoutOutputErrors(t *testing.T) {
+	stdout, stderr, err := runCLI(t, "component Broken {", "-input", "-", "-output", "-")
+	assert.Error(t, err)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "Parse error")
+
+	stdout, stderr, err = runCLI(t, "component Health { number current; }", "-input", "-", "-output", "-", "-library", "flecs")
+	assert.Error(t, err)
+	assert.Empty(t, stdout)
+	assert.Contains(t, stderr, "Error:")
+}
@@ -2,11 +2,13 @@ module github.com/ejecs/ejecs
 
 go 1.21
 
-require github.com/antlr4-go/antlr/v4 v4.13.0
+require (
+	github.com/antlr4-go/antlr/v4 v4.13.0
+	github.com/stretchr/testify v1.10.0
+)
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/stretchr/testify v1.10.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,179 @@
+// Package analysis works out which components each system reads and writes
+// and which systems can safely run at the same time. Access comes from the
+// query, where components marked mut are written, plus any reads(...) and
+// writes(...) clauses. Two systems conflict when one writes a component the
+// other reads or writes.
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// Access is the set of components a system reads and writes. A component
+// that is both read and written is only listed in Writes. Events and
+// resources count as components: emitting an event writes it, and using a
+// resource reads it unless the system also writes it.
+type Access struct {
+	Reads  []string
+	Writes []string
+}
+
+// AccessOf returns the components system reads and writes, sorted by name
+func AccessOf(system *ast.System) Access {
+	writes := make(map[string]bool)
+	reads := make(map[string]bool)
+	if system.Query != nil {
+		for _, comp := range system.Query.Mutable {
+			writes[comp] = true
+		}
+		for _, comp := range system.Query.Components {
+			reads[comp] = true
+		}
+		for _, rel := range system.Query.Relations {
+			reads[rel.Component] = true
+		}
+	}
+	for _, comp := range system.Writes {
+		writes[comp] = true
+	}
+	for _, event := range system.Emits {
+		writes[event] = true // Emitting appends to the event's queue
+	}
+	for _, comp := range system.Reads {
+		reads[comp] = true
+	}
+	for _, resource := range system.Uses {
+		reads[resource] = true // Systems that change a resource list it in writes
+	}
+	for comp := range writes {
+		delete(reads, comp)
+	}
+	return Access{Reads: sortedKeys(reads), Writes: sortedKeys(writes)}
+}
+
+// Conflict is a pair of systems that touch the same components, with at least
+// one of them writing. A runs before B.
+type Conflict struct {
+	A, B       string
+	Components []string
+	WriteWrite bool // Both systems write every component in Components
+}
+
+func (c Conflict) String() string {
+	kind := "read-write"
+	if c.WriteWrite {
+		kind = "write-write"
+	}
+	return fmt.Sprintf("%s and %s: %s conflict on %s", c.A, c.B, kind, strings.Join(c.Components, ", "))
+}
+
+// Report is the result of Analyze
+type Report struct {
+	Access    map[string]Access // By system name
+	Conflicts []Conflict        // In run order of A, then B
+	Batches   [][]string        // Consecutive groups of systems that can run in parallel
+}
+
+// Analyze builds the conflict graph of systems, given in the order they run,
+// and groups them into batches. A batch is a run of consecutive systems that
+// neither conflict nor are ordered against each other by after, before or
+// different phases, so running a batch in parallel keeps every guarantee of
+// the sequential order.
+func Analyze(systems []*ast.System) *Report {
+	report := &Report{Access: make(map[string]Access, len(systems))}
+	for _, system := range systems {
+		report.Access[system.Name] = AccessOf(system)
+	}
+
+	conflicting := make(map[[2]string]bool)
+	for i, a := range systems {
+		for _, b := range systems[i+1:] {
+			if c, ok := conflict(a.Name, b.Name, report.Access[a.Name], report.Access[b.Name]); ok {
+				report.Conflicts = append(report.Conflicts, c)
+				conflicting[[2]string{a.Name, b.Name}] = true
+			}
+		}
+	}
+
+	var batch []*ast.System
+	for _, system := range systems {
+		for _, member := range batch {
+			if conflicting[[2]string{member.Name, system.Name}] || Ordered(member, system) {
+				report.Batches = append(report.Batches, names(batch))
+				batch = nil
+				break
+			}
+		}
+		batch = append(batch, system)
+	}
+	if len(batch) > 0 {
+		report.Batches = append(report.Batches, names(batch))
+	}
+	return report
+}
+
+// Ordered reports whether a and b are explicitly ordered against each other,
+// by an after or before clause on either of them or by being in different
+// phases
+func Ordered(a, b *ast.System) bool {
+	if a.Phase != "" && b.Phase != "" && a.Phase != b.Phase {
+		return true
+	}
+	return contains(a.After, b.Name) || contains(a.Before, b.Name) ||
+		contains(b.After, a.Name) || contains(b.Before, a.Name)
+}
+
+// conflict compares the access of two systems
+func conflict(a, b string, accessA, accessB Access) (Conflict, bool) {
+	var shared, writeWrite []string
+	for _, comp := range accessA.Writes {
+		if contains(accessB.Writes, comp) {
+			writeWrite = append(writeWrite, comp)
+		} else if contains(accessB.Reads, comp) {
+			shared = append(shared, comp)
+		}
+	}
+	for _, comp := range accessA.Reads {
+		if contains(accessB.Writes, comp) {
+			shared = append(shared, comp)
+		}
+	}
+	if len(writeWrite) > 0 {
+		return Conflict{A: a, B: b, Components: writeWrite, WriteWrite: true}, true
+	}
+	if len(shared) > 0 {
+		sort.Strings(shared)
+		return Conflict{A: a, B: b, Components: shared}, true
+	}
+	return Conflict{}, false
+}
+
+func names(systems []*ast.System) []string {
+	out := make([]string, len(systems))
+	for i, system := range systems {
+		out[i] = system.Name
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
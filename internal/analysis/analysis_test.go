@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"testing"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+// systems parses input and returns its systems in declaration order
+func systems(t *testing.T, input string) []*ast.System {
+	t.Helper()
+	program, err := parser.New(input).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	var out []*ast.System
+	for _, stmt := range program.Statements {
+		if system, ok := stmt.(*ast.System); ok {
+			out = append(out, system)
+		}
+	}
+	return out
+}
+
+func TestAccessOf(t *testing.T) {
+	sys := systems(t, `system Move {
+		query(mut Transform, Velocity, ChildOf(Parent))
+		reads(Gravity, Velocity)
+		writes(Stats)
+	}`)[0]
+
+	access := AccessOf(sys)
+	assert.Equal(t, []string{"Gravity", "Parent", "Velocity"}, access.Reads)
+	assert.Equal(t, []string{"Stats", "Transform"}, access.Writes)
+}
+
+func TestAccessOf_Resources(t *testing.T) {
+	s := systems(t, `
+system Clock { uses GameTime, Settings writes(GameTime) }`)[0]
+
+	access := AccessOf(s)
+	assert.Equal(t, []string{"Settings"}, access.Reads)
+	assert.Equal(t, []string{"GameTime"}, access.Writes)
+}
+
+func TestAnalyze(t *testing.T) {
+	input := `
+system Input { query(mut Controls) }
+system Animate { query(mut Animator, Velocity) }
+system Move { query(mut Transform, Velocity) }
+system Physics { query(mut Velocity) }
+system Camera { query(Transform) }
+system Audio { query(Sound) after: Physics }
+system Snap { writes(Transform) }`
+
+	report := Analyze(systems(t, input))
+
+	assert.Equal(t, []Conflict{
+		{A: "Animate", B: "Physics", Components: []string{"Velocity"}},
+		{A: "Move", B: "Physics", Components: []string{"Velocity"}},
+		{A: "Move", B: "Camera", Components: []string{"Transform"}},
+		{A: "Move", B: "Snap", Components: []string{"Transform"}, WriteWrite: true},
+		{A: "Camera", B: "Snap", Components: []string{"Transform"}},
+	}, report.Conflicts)
+	assert.Equal(t, [][]string{
+		{"Input", "Animate", "Move"},
+		{"Physics", "Camera"},
+		{"Audio", "Snap"}, // Audio doesn't conflict with Physics but is ordered after it
+	}, report.Batches)
+	assert.Equal(t, "Move and Snap: write-write conflict on Transform", report.Conflicts[3].String())
+}
+
+func TestOrdered(t *testing.T) {
+	s := systems(t, `
+system A { phase: PreSimulation }
+system B { phase: PostSimulation }
+system C { phase: PreSimulation before: D }
+system D { }`)
+
+	assert.True(t, Ordered(s[0], s[1]))
+	assert.False(t, Ordered(s[0], s[2]))
+	assert.True(t, Ordered(s[3], s[2]))
+	assert.False(t, Ordered(s[0], s[3]))
+}
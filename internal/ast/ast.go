@@ -37,14 +37,25 @@ func (p *Program) String() string {
 // Component represents a component declaration
 type Component struct {
 	Name       string
+	TypeParams []string // Set on generic components, which are only templates for their instances
+	Instance   string   // Generic instance such as Stat<number> the parser built Fields from
+	Extends    string   // Component whose fields come first, empty for none
+	Inherited  int      // Number of leading Fields copied from Extends by the parser
 	Fields     []*Field
 	Attributes []string
+	Version    int          // Set by @version(n), 0 when unversioned
+	Migrations []*Migration // migrate from N { ... } blocks
+	Line       int
+	Column     int
 }
 
 func (c *Component) TokenLiteral() string { return "component" }
 func (c *Component) String() string {
 	var out strings.Builder
 	// Add attributes if present
+	if c.Version > 0 {
+		out.WriteString(fmt.Sprintf("@version(%d)\n", c.Version))
+	}
 	if len(c.Attributes) > 0 {
 		for i, attr := range c.Attributes {
 			out.WriteString("@")
@@ -57,9 +68,28 @@ func (c *Component) String() string {
 	}
 	out.WriteString("component ")
 	out.WriteString(c.Name)
+	if c.Instance != "" {
+		out.WriteString(" = ")
+		out.WriteString(c.Instance)
+		out.WriteString(";")
+		return out.String()
+	}
+	if len(c.TypeParams) > 0 {
+		out.WriteString("<")
+		out.WriteString(strings.Join(c.TypeParams, ", "))
+		out.WriteString(">")
+	}
+	if c.Extends != "" {
+		out.WriteString(" extends ")
+		out.WriteString(c.Extends)
+	}
 	out.WriteString(" {\n")
-	for _, field := range c.Fields {
+	for _, field := range c.Fields[c.Inherited:] {
 		out.WriteString("    ")
+		for _, attr := range field.Attributes {
+			out.WriteString(attr.String())
+			out.WriteString(" ")
+		}
 		out.WriteString(field.Name)
 		out.WriteString(": ")
 		out.WriteString(field.Type)
@@ -72,10 +102,183 @@ func (c *Component) String() string {
 		}
 		out.WriteString("\n")
 	}
+	for _, m := range c.Migrations {
+		out.WriteString("    ")
+		out.WriteString(m.String())
+		out.WriteString("\n")
+	}
 	out.WriteString("}")
 	return out.String()
 }
 
+// IsGeneric reports whether c is a template with type parameters. Only its
+// instances are registered.
+func (c *Component) IsGeneric() bool {
+	return len(c.TypeParams) > 0
+}
+
+// Const represents a top-level constant declaration: const NAME: type = value;
+type Const struct {
+	Name  string
+	Type  string // Empty when omitted
+	Value Expression
+}
+
+func (c *Const) TokenLiteral() string { return "const" }
+func (c *Const) String() string {
+	if c.Type == "" {
+		return fmt.Sprintf("const %s = %s;", c.Name, c.Value.String())
+	}
+	return fmt.Sprintf("const %s: %s = %s;", c.Name, c.Type, c.Value.String())
+}
+
+// Event represents an event declaration: a message type that systems emit
+// and read through a queue
+type Event struct {
+	Name   string
+	Fields []*Field
+	Line   int
+	Column int
+}
+
+func (e *Event) TokenLiteral() string { return "event" }
+func (e *Event) String() string {
+	var fields []string
+	for _, f := range e.Fields {
+		fields = append(fields, f.String()+";")
+	}
+	return fmt.Sprintf("event %s { %s }", e.Name, strings.Join(fields, " "))
+}
+
+// Resource represents a resource declaration: a typed singleton per world,
+// such as a game clock or the input state
+type Resource struct {
+	Name   string
+	Fields []*Field
+	Line   int
+	Column int
+}
+
+func (r *Resource) TokenLiteral() string { return "resource" }
+func (r *Resource) String() string {
+	var fields []string
+	for _, f := range r.Fields {
+		field := f.String()
+		if f.DefaultValue != nil {
+			field += " = " + f.DefaultValue.String()
+		}
+		fields = append(fields, field+";")
+	}
+	return fmt.Sprintf("resource %s { %s }", r.Name, strings.Join(fields, " "))
+}
+
+// Prefab represents a prefab declaration: a named set of components, with
+// field overrides, that entities are spawned from
+type Prefab struct {
+	Name       string
+	Extends    string // Prefab whose components this one starts from, empty for none
+	Components []*PrefabComponent
+	Line       int
+	Column     int
+}
+
+func (p *Prefab) TokenLiteral() string { return "prefab" }
+func (p *Prefab) String() string {
+	var comps []string
+	for _, c := range p.Components {
+		comps = append(comps, c.String()+";")
+	}
+	if p.Extends != "" {
+		return fmt.Sprintf("prefab %s extends %s { %s }", p.Name, p.Extends, strings.Join(comps, " "))
+	}
+	return fmt.Sprintf("prefab %s { %s }", p.Name, strings.Join(comps, " "))
+}
+
+// PrefabComponent is a component of a prefab and the fields it overrides
+type PrefabComponent struct {
+	Name      string
+	Overrides []*PrefabOverride
+}
+
+func (c *PrefabComponent) String() string {
+	if len(c.Overrides) == 0 {
+		return c.Name
+	}
+	overrides := make([]string, len(c.Overrides))
+	for i, o := range c.Overrides {
+		overrides[i] = fmt.Sprintf("%s = %s", o.Field, o.Value.String())
+	}
+	return fmt.Sprintf("%s { %s }", c.Name, strings.Join(overrides, ", "))
+}
+
+// PrefabOverride sets a field of a prefab component
+type PrefabOverride struct {
+	Field string
+	Value Expression
+}
+
+// Observer represents an observer declaration: code run when a component is
+// added to, set on or removed from an entity
+type Observer struct {
+	Name      string
+	Trigger   string     // "added", "changed" or "removed"
+	Component string     // The observed component
+	Where     Expression // Condition on the component, nil for none
+	Code      string
+	Line      int
+	Column    int
+}
+
+func (o *Observer) TokenLiteral() string { return "observer" }
+func (o *Observer) String() string {
+	on := fmt.Sprintf("on %s(%s)", o.Trigger, o.Component)
+	if o.Where != nil {
+		on += " where " + o.Where.String()
+	}
+	return fmt.Sprintf("observer %s { %s { %s } }", o.Name, on, o.Code)
+}
+
+// Migration upgrades saved data from version From to From+1
+type Migration struct {
+	From  int
+	Steps []*MigrationStep
+}
+
+func (m *Migration) TokenLiteral() string { return "migrate" }
+func (m *Migration) String() string {
+	var steps []string
+	for _, s := range m.Steps {
+		steps = append(steps, s.String()+";")
+	}
+	return fmt.Sprintf("migrate from %d { %s }", m.From, strings.Join(steps, " "))
+}
+
+// Migration step actions
+const (
+	MigrateRename  = "rename"
+	MigrateDefault = "default"
+	MigrateRemove  = "remove"
+)
+
+// MigrationStep is a single rename, default or remove inside a migrate block
+type MigrationStep struct {
+	Action string
+	Field  string
+	To     string     // New name for rename
+	Value  Expression // Value for default
+}
+
+func (s *MigrationStep) String() string {
+	switch s.Action {
+	case MigrateRename:
+		return fmt.Sprintf("rename %s -> %s", s.Field, s.To)
+	case MigrateDefault:
+		return fmt.Sprintf("default %s = %s", s.Field, s.Value.String())
+	default:
+		return fmt.Sprintf("%s %s", s.Action, s.Field)
+	}
+}
+
 // --- Expression Nodes ---
 
 type Expression interface {
@@ -118,6 +321,12 @@ func (bl *BooleanLiteral) expressionNode()      {}
 func (bl *BooleanLiteral) TokenLiteral() string { return "BOOLEAN" }
 func (bl *BooleanLiteral) String() string       { return fmt.Sprintf("%t", bl.Value) }
 
+type NilLiteral struct{}
+
+func (nl *NilLiteral) expressionNode()      {}
+func (nl *NilLiteral) TokenLiteral() string { return "NIL" }
+func (nl *NilLiteral) String() string       { return "nil" }
+
 // CallExpression represents a function call like CFrame.new(...)
 type CallExpression struct {
 	Function  Expression   // The expression being called (e.g., Identifier "CFrame.new")
@@ -176,6 +385,48 @@ func (pe *PrefixExpression) String() string {
 	return fmt.Sprintf("(%s%s)", pe.Operator, pe.Right.String())
 }
 
+// InfixExpression is a binary operation such as a + b or a && b
+type InfixExpression struct {
+	Left     Expression
+	Operator string // As written in EJECS, e.g. "&&" or "!="
+	Right    Expression
+}
+
+func (ie *InfixExpression) expressionNode()      {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Operator }
+func (ie *InfixExpression) String() string {
+	return fmt.Sprintf("(%s %s %s)", ie.Left.String(), ie.Operator, ie.Right.String())
+}
+
+// IndexExpression represents indexing such as list[1] or Enum.KeyCode["W"]
+type IndexExpression struct {
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return "[" }
+func (ie *IndexExpression) String() string {
+	return fmt.Sprintf("%s[%s]", ie.Left.String(), ie.Index.String())
+}
+
+// MethodCallExpression represents a Luau method call such as a:Lerp(b, t)
+type MethodCallExpression struct {
+	Object    Expression
+	Method    *Identifier
+	Arguments []Expression
+}
+
+func (mc *MethodCallExpression) expressionNode()      {}
+func (mc *MethodCallExpression) TokenLiteral() string { return ":" }
+func (mc *MethodCallExpression) String() string {
+	var args []string
+	for _, a := range mc.Arguments {
+		args = append(args, a.String())
+	}
+	return fmt.Sprintf("%s:%s(%s)", mc.Object.String(), mc.Method.Value, strings.Join(args, ", "))
+}
+
 // --- Add MemberAccessExpression Node ---
 type MemberAccessExpression struct {
 	Object     Expression  // The expression on the left of the dot (e.g., Identifier "CFrame")
@@ -193,9 +444,20 @@ type Field struct {
 	Name         string
 	Type         string // Base type (e.g., "int", "Vector3", "table")
 	Optional     bool
-	MapKeyType   string     // Used if Type is "table"
-	MapValueType string     // Used if Type is "table"
-	DefaultValue Expression // Changed from string to Expression node
+	MapKeyType   string       // Used if Type is "table"
+	MapValueType string       // Used if Type is "table"
+	DefaultValue Expression   // Changed from string to Expression node
+	Attributes   []*Attribute // Field attributes such as @min(0)
+}
+
+// Attribute returns the first attribute with the given name, or nil
+func (f *Field) Attribute(name string) *Attribute {
+	for _, attr := range f.Attributes {
+		if attr.Name == name {
+			return attr
+		}
+	}
+	return nil
 }
 
 func (f *Field) TokenLiteral() string { return "field" }
@@ -207,6 +469,24 @@ func (f *Field) String() string {
 	return fmt.Sprintf("%s: %s%s", f.Name, f.Type, opt)
 }
 
+// Attribute represents a field attribute like @transient or @range(0, 100)
+type Attribute struct {
+	Name      string
+	Arguments []Expression
+}
+
+func (a *Attribute) TokenLiteral() string { return "@" }
+func (a *Attribute) String() string {
+	if len(a.Arguments) == 0 {
+		return "@" + a.Name
+	}
+	var args []string
+	for _, arg := range a.Arguments {
+		args = append(args, arg.String())
+	}
+	return fmt.Sprintf("@%s(%s)", a.Name, strings.Join(args, ", "))
+}
+
 // Relationship represents a relationship declaration
 type Relationship struct {
 	Type   string
@@ -252,6 +532,13 @@ type System struct {
 	Query      *Query
 	Frequency  Expression // Changed from string
 	Priority   Expression // Changed from string
+	Phase      string     // Frame stage the system runs in, empty for none
+	After      []string   // Systems that must run before this one
+	Before     []string   // Systems that must run after this one
+	Reads      []string   // Components read in addition to the query
+	Writes     []string   // Components written in addition to mut query terms
+	Emits      []string   // Events the system emits
+	Uses       []string   // Resources passed to the callback
 	Code       string
 	Line       int
 	Column     int
@@ -286,6 +573,11 @@ func (s *System) String() string {
 			if i > 0 {
 				out.WriteString(", ")
 			}
+			for _, mutable := range s.Query.Mutable {
+				if mutable == comp {
+					out.WriteString("mut ")
+				}
+			}
 			out.WriteString(comp)
 		}
 		out.WriteString("]\n")
@@ -297,6 +589,11 @@ func (s *System) String() string {
 			out.WriteString(rel.String())
 		}
 		out.WriteString("]\n")
+		if s.Query.Where != nil {
+			out.WriteString("        where: ")
+			out.WriteString(s.Query.Where.String())
+			out.WriteString("\n")
+		}
 		out.WriteString("    }\n")
 	} else if len(s.Components) > 0 { // Fallback for old Components field if Query is nil (for old tests)
 		out.WriteString("    using ") // Re-add "using" for compatibility if needed
@@ -313,6 +610,41 @@ func (s *System) String() string {
 		out.WriteString(s.Priority.String())
 		out.WriteString("\n")
 	}
+	if s.Phase != "" {
+		out.WriteString("    phase: ")
+		out.WriteString(s.Phase)
+		out.WriteString("\n")
+	}
+	if len(s.After) > 0 {
+		out.WriteString("    after: ")
+		out.WriteString(strings.Join(s.After, ", "))
+		out.WriteString("\n")
+	}
+	if len(s.Before) > 0 {
+		out.WriteString("    before: ")
+		out.WriteString(strings.Join(s.Before, ", "))
+		out.WriteString("\n")
+	}
+	if len(s.Reads) > 0 {
+		out.WriteString("    reads(")
+		out.WriteString(strings.Join(s.Reads, ", "))
+		out.WriteString(")\n")
+	}
+	if len(s.Writes) > 0 {
+		out.WriteString("    writes(")
+		out.WriteString(strings.Join(s.Writes, ", "))
+		out.WriteString(")\n")
+	}
+	if len(s.Emits) > 0 {
+		out.WriteString("    emits ")
+		out.WriteString(strings.Join(s.Emits, ", "))
+		out.WriteString("\n")
+	}
+	if len(s.Uses) > 0 {
+		out.WriteString("    uses ")
+		out.WriteString(strings.Join(s.Uses, ", "))
+		out.WriteString("\n")
+	}
 	if s.Code != "" {
 		out.WriteString("    code: {\n")
 		// Basic code indentation
@@ -331,7 +663,9 @@ func (s *System) String() string {
 // Query represents a system's query
 type Query struct {
 	Components []string
+	Mutable    []string // Components marked mut, which the system writes
 	Relations  []*Relation
+	Where      Expression // Condition on the components an entity must meet, nil for none
 }
 
 func (q *Query) TokenLiteral() string { return "query" }
@@ -341,6 +675,9 @@ func (q *Query) String() string {
 	for _, r := range q.Relations {
 		parts = append(parts, r.String())
 	}
+	if q.Where != nil {
+		return fmt.Sprintf("query: (%s) where %s;", strings.Join(parts, ", "), q.Where.String())
+	}
 	return fmt.Sprintf("query: (%s);", strings.Join(parts, ", "))
 }
 
@@ -33,6 +33,37 @@ func TestComponent_String(t *testing.T) {
 			},
 			expected: "@replicated @networked\ncomponent Player {\n    name: string\n}",
 		},
+		{
+			name: "component with inherited fields",
+			comp: &Component{
+				Name:      "Enemy",
+				Extends:   "Actor",
+				Inherited: 1,
+				Fields: []*Field{
+					{Name: "model", Type: "Instance"},
+					{Name: "aggro", Type: "number"},
+				},
+			},
+			expected: "component Enemy extends Actor {\n    aggro: number\n}",
+		},
+		{
+			name: "generic component",
+			comp: &Component{
+				Name:       "Stat",
+				TypeParams: []string{"T"},
+				Fields:     []*Field{{Name: "base", Type: "T"}},
+			},
+			expected: "component Stat<T> {\n    base: T\n}",
+		},
+		{
+			name: "generic instance",
+			comp: &Component{
+				Name:     "Health",
+				Instance: "Stat<number>",
+				Fields:   []*Field{{Name: "base", Type: "number"}},
+			},
+			expected: "component Health = Stat<number>;",
+		},
 	}
 
 	for _, tt := range tests {
@@ -97,6 +128,16 @@ func TestSystem_String(t *testing.T) {
 			},
 			expected: "system Physics {\n    query: {\n        components: [RigidBody]\n        relations: []\n    }\n    frequency: 60hz\n    priority: 1\n}",
 		},
+		{
+			name: "system with ordering constraints",
+			sys: &System{
+				Name:   "Movement",
+				Phase:  "PreSimulation",
+				After:  []string{"Input", "Physics"},
+				Before: []string{"Render"},
+			},
+			expected: "system Movement {\n    phase: PreSimulation\n    after: Input, Physics\n    before: Render\n}",
+		},
 	}
 
 	for _, tt := range tests {
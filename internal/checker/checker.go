@@ -0,0 +1,632 @@
+// Package checker infers the types of default-value expressions and checks
+// them against the declared types of component fields and system parameters.
+// It looks up Roblox constructors and enums in the robloxapi database, so
+// mistakes like `Vector3 p = CFrame.new();` or `Vector3.new(1, 2, 3, 4)` are
+// reported at compile time instead of when the generated module runs. It also
+// checks the declarations of a program against each other, such as prefabs
+// extending undeclared prefabs or systems emitting undeclared events, so the
+// generator only has to emit code.
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/eval"
+	"github.com/ejecs/ejecs/internal/robloxapi"
+)
+
+// Any is the inferred type of expressions the checker cannot see into, such as
+// unknown identifiers or arbitrary function calls. It is compatible with every
+// declared type.
+const Any = "any"
+
+// Checker checks default values, resolving identifiers against folded consts
+type Checker struct {
+	consts   map[string]eval.Value
+	scope    map[string]*ast.Component // Components a where clause can read, nil outside one
+	warnings []string                  // Problems that don't stop generation
+	warned   map[string]bool           // Enums already reported in warnings
+}
+
+// New creates a Checker. consts may be nil.
+func New(consts map[string]eval.Value) *Checker {
+	return &Checker{consts: consts}
+}
+
+// Warnings returns the problems found by the checks run so far that don't
+// make the program invalid, such as enums missing from the bundled database
+func (c *Checker) Warnings() []string {
+	return c.warnings
+}
+
+// Check verifies every field and parameter default in program, the
+// components and overrides of prefabs, the where clauses of queries and
+// observers, and that declarations are unique and refer to declared names
+func (c *Checker) Check(program *ast.Program) error {
+	components := make(map[string]*ast.Component)
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok && !comp.IsGeneric() {
+			components[comp.Name] = comp
+		}
+	}
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			if n.IsGeneric() {
+				continue // Checked through its instances
+			}
+			for _, field := range n.Fields {
+				if err := c.CheckField(field); err != nil {
+					return fmt.Errorf("component %s: field '%s': %v", n.Name, field.Name, err)
+				}
+			}
+		case *ast.Event:
+			for _, field := range n.Fields {
+				err := c.CheckField(field)
+				if err == nil && field.DefaultValue != nil {
+					err = fmt.Errorf("event fields cannot have default values")
+				}
+				if err != nil {
+					return fmt.Errorf("event %s: field '%s': %v", n.Name, field.Name, err)
+				}
+			}
+		case *ast.Resource:
+			for _, field := range n.Fields {
+				if err := c.CheckField(field); err != nil {
+					return fmt.Errorf("resource %s: field '%s': %v", n.Name, field.Name, err)
+				}
+			}
+		case *ast.Prefab:
+			if err := c.checkPrefab(n, components); err != nil {
+				return fmt.Errorf("prefab %s: %v", n.Name, err)
+			}
+		case *ast.Observer:
+			comp, ok := components[n.Component]
+			if !ok {
+				return fmt.Errorf("observer %s: unknown component %s", n.Name, n.Component)
+			}
+			if n.Where != nil {
+				if err := c.checkWhere(n.Where, []*ast.Component{comp}, components); err != nil {
+					return fmt.Errorf("observer %s: where: %v", n.Name, err)
+				}
+			}
+		case *ast.System:
+			if n.Query != nil && n.Query.Where != nil {
+				var available []*ast.Component
+				for _, name := range n.Query.Components {
+					if comp, ok := components[name]; ok {
+						available = append(available, comp)
+					}
+				}
+				if err := c.checkWhere(n.Query.Where, available, components); err != nil {
+					return fmt.Errorf("system %s: where: %v", n.Name, err)
+				}
+			}
+			for _, param := range n.Parameters {
+				err := c.checkTypeName(param.Type)
+				if err == nil && param.DefaultValue != nil {
+					err = c.checkValue(param.Type, param.DefaultValue)
+				}
+				if err != nil {
+					return fmt.Errorf("system %s: parameter '%s': %v", n.Name, param.Name, err)
+				}
+			}
+		}
+	}
+	return c.checkDeclarations(program)
+}
+
+// checkPrefab checks that a prefab names declared components at most once and
+// that every override sets a field of its component to a value of its type
+func (c *Checker) checkPrefab(prefab *ast.Prefab, components map[string]*ast.Component) error {
+	seen := make(map[string]bool)
+	for _, pc := range prefab.Components {
+		comp, ok := components[pc.Name]
+		if !ok {
+			return fmt.Errorf("unknown component %s", pc.Name)
+		}
+		if seen[pc.Name] {
+			return fmt.Errorf("duplicate component %s", pc.Name)
+		}
+		seen[pc.Name] = true
+
+		set := make(map[string]bool)
+		for _, override := range pc.Overrides {
+			var field *ast.Field
+			for _, f := range comp.Fields {
+				if f.Name == override.Field {
+					field = f
+				}
+			}
+			if field == nil {
+				return fmt.Errorf("component %s has no field '%s'", pc.Name, override.Field)
+			}
+			if set[override.Field] {
+				return fmt.Errorf("%s.%s is overridden twice", pc.Name, override.Field)
+			}
+			set[override.Field] = true
+
+			withValue := *field
+			withValue.DefaultValue = override.Value
+			if err := c.CheckField(&withValue); err != nil {
+				return fmt.Errorf("%s.%s: %v", pc.Name, override.Field, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkWhere checks that a where clause is a boolean condition on the fields
+// of available. Other components of the program can't be read.
+func (c *Checker) checkWhere(where ast.Expression, available []*ast.Component, components map[string]*ast.Component) error {
+	c.scope = make(map[string]*ast.Component, len(components))
+	defer func() { c.scope = nil }()
+	for name := range components {
+		c.scope[name] = nil
+	}
+	for _, comp := range available {
+		c.scope[comp.Name] = comp
+	}
+
+	t, err := c.Infer(where)
+	if err != nil {
+		return err
+	}
+	if t != Any && t != "boolean" {
+		return fmt.Errorf("expected boolean, got %s", t)
+	}
+	return nil
+}
+
+// CheckField checks a field's default value, including the keys and values of
+// table constructors assigned to table<K, V> fields
+func (c *Checker) CheckField(field *ast.Field) error {
+	for _, t := range []string{field.Type, field.MapValueType} {
+		if err := c.checkTypeName(t); err != nil {
+			return err
+		}
+	}
+	if field.DefaultValue == nil {
+		return nil
+	}
+	if _, ok := field.DefaultValue.(*ast.NilLiteral); ok {
+		if !field.Optional {
+			return fmt.Errorf("only optional fields can default to nil")
+		}
+		return nil
+	}
+	if field.Type != "table" {
+		return c.checkValue(field.Type, field.DefaultValue)
+	}
+
+	tbl, ok := field.DefaultValue.(*ast.TableConstructor)
+	if !ok {
+		got, err := c.Infer(field.DefaultValue)
+		if err != nil {
+			return err
+		}
+		if got != Any && got != "table" {
+			return fmt.Errorf("expected table<%s, %s>, got %s", field.MapKeyType, field.MapValueType, got)
+		}
+		return nil
+	}
+	for i, entry := range tbl.Fields {
+		keyType := "number" // Array-style entries have numeric keys
+		switch key := entry.Key.(type) {
+		case nil:
+		case *ast.Identifier:
+			keyType = "string" // { name = value }
+		default:
+			var err error
+			if keyType, err = c.Infer(key); err != nil {
+				return err
+			}
+		}
+		if !assignable(field.MapKeyType, keyType) {
+			return fmt.Errorf("entry %d: expected %s key, got %s", i+1, field.MapKeyType, keyType)
+		}
+		if err := c.checkValue(field.MapValueType, entry.Value); err != nil {
+			return fmt.Errorf("entry %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// checkValue checks that expr can be stored in a value of type declared
+func (c *Checker) checkValue(declared string, expr ast.Expression) error {
+	got, err := c.Infer(expr)
+	if err != nil {
+		return err
+	}
+	if !assignable(declared, got) {
+		return fmt.Errorf("expected %s, got %s", declared, got)
+	}
+	if declared == "int" {
+		if value, err := eval.Eval(expr, c.consts); err == nil && !value.IsInteger() {
+			return fmt.Errorf("expected int, got %s", value.Luau())
+		}
+	}
+	return nil
+}
+
+// checkTypeName rejects Enum.X types naming enums that do not exist
+func (c *Checker) checkTypeName(t string) error {
+	if enum, ok := strings.CutPrefix(t, "Enum."); ok && robloxapi.Current().Enum(enum) == nil {
+		return c.unknownEnum(enum)
+	}
+	return nil
+}
+
+// unknownEnum reports an enum missing from the database. The bundled database
+// only lists common enums, so without an API dump a missing enum is a warning
+// and values of it are trusted.
+func (c *Checker) unknownEnum(enum string) error {
+	if robloxapi.Current() != robloxapi.Bundled() {
+		return fmt.Errorf("unknown enum Enum.%s", enum)
+	}
+	if !c.warned[enum] {
+		if c.warned == nil {
+			c.warned = make(map[string]bool)
+		}
+		c.warned[enum] = true
+		c.warnings = append(c.warnings, fmt.Sprintf("Enum.%s is not in the bundled Roblox API database and is not checked; pass -api with a current API-Dump.json to check it", enum))
+	}
+	return nil
+}
+
+// IsDataType reports whether t names a Roblox datatype, such as Vector3, in
+// the current robloxapi database
+func IsDataType(t string) bool {
+	return robloxapi.Current().IsDataType(t)
+}
+
+// assignable reports whether a value of inferred type got fits declared. Enum
+// items are typed by their enum (Enum.Material) and fit EnumItem as well.
+func assignable(declared, got string) bool {
+	if got == Any {
+		return true
+	}
+	if strings.HasPrefix(declared, "Enum.") {
+		return got == declared || got == "EnumItem"
+	}
+	if declared == "EnumItem" && strings.HasPrefix(got, "Enum.") {
+		return true
+	}
+	switch declared {
+	case "number", "int", "float":
+		return got == "number"
+	case "string", "boolean", "table":
+		return got == declared
+	default:
+		if IsDataType(declared) {
+			return got == declared
+		}
+		return true // any, component names and other unchecked types
+	}
+}
+
+// valueType returns the type expressions infer for values of a field type:
+// int and float fields hold Luau numbers
+func valueType(t string) string {
+	switch t {
+	case "int", "float":
+		return "number"
+	}
+	return t
+}
+
+// Infer returns the type of expr, or Any when it cannot be determined
+func (c *Checker) Infer(expr ast.Expression) (string, error) {
+	switch e := expr.(type) {
+	case *ast.NumberLiteral:
+		return "number", nil
+	case *ast.StringLiteral:
+		return "string", nil
+	case *ast.BooleanLiteral:
+		return "boolean", nil
+	case *ast.NilLiteral:
+		return "nil", nil
+	case *ast.TableConstructor:
+		return "table", nil
+	case *ast.Identifier:
+		if comp, ok := c.scope[e.Value]; ok && comp == nil {
+			return "", fmt.Errorf("component %s is not available here", e.Value)
+		}
+		if value, ok := c.consts[e.Value]; ok {
+			return value.Kind.String(), nil
+		}
+		return Any, nil
+	case *ast.PrefixExpression:
+		right, err := c.Infer(e.Right)
+		if err != nil {
+			return "", err
+		}
+		return inferPrefix(e.Operator, right)
+	case *ast.InfixExpression:
+		left, err := c.Infer(e.Left)
+		if err != nil {
+			return "", err
+		}
+		right, err := c.Infer(e.Right)
+		if err != nil {
+			return "", err
+		}
+		return inferInfix(e.Operator, left, right)
+	case *ast.MemberAccessExpression:
+		return c.inferMember(e)
+	case *ast.IndexExpression:
+		if isEnum(e.Left) {
+			enum := e.Left.(*ast.MemberAccessExpression).MemberName.Value
+			if key, ok := e.Index.(*ast.StringLiteral); ok {
+				return c.enumItemType(enum, key.Value)
+			}
+			// Enum.KeyCode[name]
+			if err := c.checkTypeName("Enum." + enum); err != nil {
+				return "", err
+			}
+			return "Enum." + enum, nil
+		}
+		return Any, nil
+	case *ast.CallExpression:
+		return c.inferCall(e)
+	case *ast.MethodCallExpression:
+		object, err := c.Infer(e.Object)
+		if err != nil {
+			return "", err
+		}
+		if dt := robloxapi.Current().DataType(object); dt != nil {
+			if t, ok := dt.Methods[e.Method.Value]; ok {
+				return t, nil
+			}
+		}
+		return Any, nil
+	default:
+		return Any, nil
+	}
+}
+
+func inferPrefix(op, right string) (string, error) {
+	if op == "!" {
+		return "boolean", nil // not works on any value
+	}
+	switch right {
+	case Any, "number", "Vector2", "Vector3", "UDim", "UDim2":
+		return right, nil
+	}
+	return "", fmt.Errorf("cannot negate %s", right)
+}
+
+func inferInfix(op, left, right string) (string, error) {
+	switch op {
+	case "==", "!=":
+		if !assignable(left, right) && !assignable(right, left) {
+			return "", fmt.Errorf("cannot compare %s and %s", left, right)
+		}
+		return "boolean", nil
+	case "<", ">", "<=", ">=":
+		// Luau orders numbers and strings, each only with its own kind
+		for _, t := range []string{"number", "string"} {
+			if (left == t || left == Any) && (right == t || right == Any) {
+				return "boolean", nil
+			}
+		}
+		return "", fmt.Errorf("cannot apply %s to %s and %s", op, left, right)
+	case "&&", "||":
+		if left == "boolean" && right == "boolean" {
+			return "boolean", nil
+		}
+		return Any, nil
+	case "..":
+		for _, t := range []string{left, right} {
+			if t != Any && t != "string" && t != "number" {
+				return "", fmt.Errorf("cannot apply .. to %s and %s", left, right)
+			}
+		}
+		return "string", nil
+	}
+
+	if left == Any || right == Any {
+		return Any, nil
+	}
+	if left == "number" && right == "number" {
+		return "number", nil
+	}
+	if t, ok := arithmetic[left+" "+op+" "+right]; ok {
+		return t, nil
+	}
+	return "", fmt.Errorf("cannot apply %s to %s and %s", op, left, right)
+}
+
+// arithmetic lists the operators Roblox types overload, keyed by
+// "left op right"
+var arithmetic = map[string]string{
+	"Vector2 + Vector2": "Vector2",
+	"Vector2 - Vector2": "Vector2",
+	"Vector2 * Vector2": "Vector2",
+	"Vector2 / Vector2": "Vector2",
+	"Vector2 * number":  "Vector2",
+	"number * Vector2":  "Vector2",
+	"Vector2 / number":  "Vector2",
+	"Vector3 + Vector3": "Vector3",
+	"Vector3 - Vector3": "Vector3",
+	"Vector3 * Vector3": "Vector3",
+	"Vector3 / Vector3": "Vector3",
+	"Vector3 * number":  "Vector3",
+	"number * Vector3":  "Vector3",
+	"Vector3 / number":  "Vector3",
+	"CFrame * CFrame":   "CFrame",
+	"CFrame * Vector3":  "Vector3",
+	"CFrame + Vector3":  "CFrame",
+	"CFrame - Vector3":  "CFrame",
+	"UDim + UDim":       "UDim",
+	"UDim - UDim":       "UDim",
+	"UDim2 + UDim2":     "UDim2",
+	"UDim2 - UDim2":     "UDim2",
+}
+
+// globals lists the types of Luau library values
+var globals = map[string]map[string]string{
+	"math": {"pi": "number", "huge": "number"},
+}
+
+// isEnum reports whether expr is Enum.<Name>
+func isEnum(expr ast.Expression) bool {
+	ma, ok := expr.(*ast.MemberAccessExpression)
+	if !ok {
+		return false
+	}
+	ident, ok := ma.Object.(*ast.Identifier)
+	return ok && ident.Value == "Enum"
+}
+
+func (c *Checker) inferMember(ma *ast.MemberAccessExpression) (string, error) {
+	if isEnum(ma.Object) {
+		// Enum.Material.Plastic
+		return c.enumItemType(ma.Object.(*ast.MemberAccessExpression).MemberName.Value, ma.MemberName.Value)
+	}
+	if ident, ok := ma.Object.(*ast.Identifier); ok {
+		if comp, ok := c.scope[ident.Value]; ok && comp != nil {
+			// Health.current in a where clause
+			for _, field := range comp.Fields {
+				if field.Name == ma.MemberName.Value {
+					return valueType(field.Type), nil
+				}
+			}
+			return "", fmt.Errorf("component %s has no field '%s'", comp.Name, ma.MemberName.Value)
+		}
+		if dt := robloxapi.Current().DataType(ident.Value); dt != nil {
+			if t, ok := dt.Properties[ma.MemberName.Value]; ok {
+				return t, nil
+			}
+			if _, ok := dt.Constructors[ma.MemberName.Value]; ok {
+				return Any, nil // The constructor function itself
+			}
+			return "", fmt.Errorf("unknown member %s.%s", ident.Value, ma.MemberName.Value)
+		}
+		if t, ok := globals[ident.Value][ma.MemberName.Value]; ok {
+			return t, nil
+		}
+	}
+
+	// Members of values such as Vector3.new(1, 2, 3).Magnitude
+	object, err := c.Infer(ma.Object)
+	if err != nil {
+		return "", err
+	}
+	if dt := robloxapi.Current().DataType(object); dt != nil {
+		if t, ok := dt.Members[ma.MemberName.Value]; ok {
+			return t, nil
+		}
+	}
+	return Any, nil
+}
+
+// enumItemType checks that Enum.<enum>.<item> exists and returns its type,
+// Enum.<enum>. Items of enums unknownEnum lets through are not checked.
+func (c *Checker) enumItemType(enum, item string) (string, error) {
+	e := robloxapi.Current().Enum(enum)
+	if e == nil {
+		if err := c.unknownEnum(enum); err != nil {
+			return "", err
+		}
+		return "Enum." + enum, nil
+	}
+	if !e.Has(item) {
+		return "", fmt.Errorf("Enum.%s has no item %s", enum, item)
+	}
+	return "Enum." + enum, nil
+}
+
+func (c *Checker) inferCall(call *ast.CallExpression) (string, error) {
+	ma, ok := call.Function.(*ast.MemberAccessExpression)
+	if !ok {
+		return Any, nil
+	}
+	ident, ok := ma.Object.(*ast.Identifier)
+	if !ok {
+		return Any, nil
+	}
+	dt := robloxapi.Current().DataType(ident.Value)
+	if dt == nil {
+		return Any, nil
+	}
+	typeName, name := ident.Value, ma.MemberName.Value
+	overloads, ok := dt.Constructors[name]
+	if !ok {
+		return "", fmt.Errorf("unknown constructor %s.%s", typeName, name)
+	}
+
+	args := make([]string, len(call.Arguments))
+	for i, arg := range call.Arguments {
+		t, err := c.Infer(arg)
+		if err != nil {
+			return "", err
+		}
+		args[i] = t
+	}
+	if err := matchOverload(typeName+"."+name, overloads, args); err != nil {
+		return "", err
+	}
+	return typeName, nil
+}
+
+// matchOverload finds a signature accepting args
+func matchOverload(name string, overloads [][]string, args []string) error {
+	var arities []int
+	var candidates [][]string
+	for _, params := range overloads {
+		if !containsInt(arities, len(params)) {
+			arities = append(arities, len(params))
+		}
+		if len(params) == len(args) {
+			candidates = append(candidates, params)
+		}
+	}
+
+	if len(candidates) == 0 {
+		sort.Ints(arities)
+		counts := make([]string, len(arities))
+		for i, n := range arities {
+			counts[i] = fmt.Sprint(n)
+		}
+		return fmt.Errorf("%s expects %s argument(s), got %d", name, joinOr(counts), len(args))
+	}
+
+	var mismatch error
+	for _, params := range candidates {
+		mismatch = nil
+		for i, param := range params {
+			if !assignable(param, args[i]) {
+				mismatch = fmt.Errorf("%s: argument %d must be %s, got %s", name, i+1, param, args[i])
+				break
+			}
+		}
+		if mismatch == nil {
+			return nil
+		}
+	}
+	if len(candidates) > 1 {
+		return fmt.Errorf("no overload of %s accepts (%s)", name, strings.Join(args, ", "))
+	}
+	return mismatch
+}
+
+// joinOr joins items as "a, b or c"
+func joinOr(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
+
+func containsInt(list []int, n int) bool {
+	for _, item := range list {
+		if item == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,358 @@
+package checker
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/eval"
+	"github.com/ejecs/ejecs/internal/parser"
+	"github.com/ejecs/ejecs/internal/robloxapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// parseField parses a single field declaration such as "number x = 1"
+func parseField(t *testing.T, decl string) *ast.Field {
+	t.Helper()
+	program, err := parser.New(fmt.Sprintf("component T { %s; }", decl)).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	return program.Statements[0].(*ast.Component).Fields[0]
+}
+
+// useAPIDump replaces the bundled database for the rest of the test, as
+// passing -api does, so unknown enums are errors
+func useAPIDump(t *testing.T) {
+	robloxapi.Use(robloxapi.Bundled().Merge(&robloxapi.Database{}))
+	t.Cleanup(func() { robloxapi.Use(robloxapi.Bundled()) })
+}
+
+func TestInfer(t *testing.T) {
+	consts := map[string]eval.Value{"SPEED": eval.NumberValue(16)}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"16 * 1.5", "number"},
+		{"SPEED", "number"},
+		{`"Lv " .. 1`, "string"},
+		{"a < b", "boolean"},
+		{"unknown", Any},
+		{"Vector3.new(1, 2, 3)", "Vector3"},
+		{"Vector3.new(0, 1, 0) * SPEED", "Vector3"},
+		{"-Vector3.zero", "Vector3"},
+		{"CFrame.new() * Vector3.new()", "Vector3"},
+		{"CFrame.new() + Vector3.yAxis", "CFrame"},
+		{"Color3.fromRGB(255, 0, 0):Lerp(c, 0.5)", "Color3"},
+		{"UDim2.fromScale(1, 1)", "UDim2"},
+		{"UDim2.new(UDim.new(1, 0), UDim.new())", "UDim2"},
+		{"Enum.Material.Plastic", "Enum.Material"},
+		{`Enum.KeyCode["W"]`, "Enum.KeyCode"},
+		{"Enum.KeyCode[name]", "Enum.KeyCode"},
+		{"{ 1, 2 }", "table"},
+		{"math.pi * 2", "number"},
+		{"require(x)", Any},
+		{"Vector3.new(1, 2, 3).Magnitude", "number"},
+		{"Vector3int16.new(1, 2, 3)", "Vector3int16"},
+		{`Font.new("rbxasset://fonts/families/GothamSSm.json", Enum.FontWeight.Bold)`, "Font"},
+		{"TweenInfo.new(0.5, Enum.EasingStyle.Quad)", "TweenInfo"},
+	}
+
+	c := New(consts)
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := c.Infer(parseField(t, "any v = "+tt.input).DefaultValue)
+			if err != nil {
+				t.Fatalf("Infer() error = %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Infer() wrong. expected=%s, got=%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestCheckField(t *testing.T) {
+	consts := map[string]eval.Value{"HALF": eval.NumberValue(0.5)}
+	valid := []string{
+		"number walkSpeed = 16 * 1.5",
+		"int count = 10 / 2",
+		"float ratio = HALF",
+		"any anything = Vector3.new()",
+		"Vector3 p = CFrame.new() * Vector3.zero",
+		"CFrame cf = CFrame.lookAt(Vector3.new(), Vector3.new(0, 0, -1))",
+		"Color3 c = Color3.fromHex(\"#ff0000\")",
+		"EnumItem m = Enum.Material.Plastic",
+		"Enum.Material m = Enum.Material.Plastic",
+		"Enum.Material m",
+		"table<string, Enum.KeyCode> binds = { jump = Enum.KeyCode.Space }",
+		"Player owner = something",
+		"table<string, number> stats = { health = 100, [\"max\" .. \"Health\"] = 100 }",
+		"table<number, Vector3> points = { Vector3.new(), Vector3.one }",
+		"table<string, number> empty = {}",
+	}
+	for _, decl := range valid {
+		if err := New(consts).CheckField(parseField(t, decl)); err != nil {
+			t.Errorf("CheckField(%s) unexpected error: %v", decl, err)
+		}
+	}
+
+	invalid := []struct {
+		decl     string
+		expected string
+	}{
+		{`number walkSpeed = "fast"`, "expected number, got string"},
+		{"Vector3 p = CFrame.new()", "expected Vector3, got CFrame"},
+		{"int count = 1.5", "expected int, got 1.5"},
+		{"int count = HALF * 3", "expected int, got 1.5"},
+		{"boolean b = 1", "expected boolean, got number"},
+		{"Vector3 p = Vector3.new(1, 2, 3, 4)", "Vector3.new expects 0, 1, 2 or 3 argument(s), got 4"},
+		{`Color3 c = Color3.fromRGB(255, "0", 0)`, "Color3.fromRGB: argument 2 must be number, got string"},
+		{"BrickColor b = BrickColor.new(true)", "no overload of BrickColor.new accepts (boolean)"},
+		{"Vector3 p = Vector3.nwe()", "unknown constructor Vector3.nwe"},
+		{"Vector3 p = Vector3.new() + 1", "cannot apply + to Vector3 and number"},
+		{"CFrame cf = -CFrame.new()", "cannot negate CFrame"},
+		{"EnumItem m = Enum.Material.Plastik", "Enum.Material has no item Plastik"},
+		{`EnumItem k = Enum.KeyCod["W"]`, "unknown enum Enum.KeyCod"},
+		{"Vector3 p = Vector3.zro", "unknown member Vector3.zro"},
+		{"Enum.Material m = Enum.KeyCode.W", "expected Enum.Material, got Enum.KeyCode"},
+		{"Enum.Materail m", "unknown enum Enum.Materail"},
+		{"table<string, Enum.Nope> binds", "unknown enum Enum.Nope"},
+		{"table<string, number> stats = { 1, 2 }", "entry 1: expected string key, got number"},
+		{`table<string, number> stats = { health = "full" }`, "entry 1: expected number, got string"},
+		{"table<string, number> stats = 5", "expected table<string, number>, got number"},
+	}
+	useAPIDump(t)
+	for _, tt := range invalid {
+		err := New(consts).CheckField(parseField(t, tt.decl))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("CheckField(%s) error wrong. expected=%q, got=%v", tt.decl, tt.expected, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	input := `
+component Movement {
+	number speed = 16;
+}
+
+system Move {
+	query(Movement)
+	params {
+		Vector3 gravity = Vector3.new(0, -9.81, 0);
+		number damping = Vector3.new();
+	}
+}`
+	program, err := parser.New(input).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+
+	err = New(nil).Check(program)
+	expected := "system Move: parameter 'damping': expected number, got Vector3"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Check() error wrong. expected=%q, got=%v", expected, err)
+	}
+}
+
+func TestCheck_UnknownEnumWithoutAPIDump(t *testing.T) {
+	program, err := parser.New(`component Surface {
+		Enum.Technology lighting;
+		EnumItem fallback = Enum.Technology.Future;
+		table<string, Enum.Nope> binds;
+	}`).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+
+	// The bundled database lists few enums, so missing ones are only warned about
+	c := New(nil)
+	assert.NoError(t, c.Check(program))
+	assert.Equal(t, []string{
+		"Enum.Technology is not in the bundled Roblox API database and is not checked; pass -api with a current API-Dump.json to check it",
+		"Enum.Nope is not in the bundled Roblox API database and is not checked; pass -api with a current API-Dump.json to check it",
+	}, c.Warnings())
+
+	useAPIDump(t)
+	assert.EqualError(t, New(nil).Check(program), "component Surface: field 'lighting': unknown enum Enum.Technology")
+}
+
+func TestCheck_Event(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"event Damage { Enum.Nope kind; }", "event Damage: field 'kind': unknown enum Enum.Nope"},
+		{"event Damage { number amount = 5; }", "event Damage: field 'amount': event fields cannot have default values"},
+		{"component Damage { } event Damage { }", "event Damage collides with another component or event of the same name"},
+		{"event Damage { } event Damage { }", "event Damage collides with another component or event of the same name"},
+		{"system S { emits Nope }", "system S: emits unknown event Nope"},
+	}
+	useAPIDump(t)
+	for _, tt := range tests {
+		program, err := parser.New(tt.input).ParseProgram()
+		if err != nil {
+			t.Fatalf("ParseProgram error: %v", err)
+		}
+		if err := New(nil).Check(program); err == nil || err.Error() != tt.expected {
+			t.Errorf("Check(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCheck_EventWarnings(t *testing.T) {
+	program, err := parser.New(`event Damage { number amount; }
+	event Heal { number amount; }
+	event Death { }
+	system Hit { emits Damage }
+	system Apply { reads Heal, Death emits Death }`).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+
+	// Code outside the schema may emit or read events, so these only warn
+	c := New(nil)
+	assert.NoError(t, c.Check(program))
+	assert.Equal(t, []string{
+		"event Damage is emitted but no system reads it",
+		"event Heal is read but no system emits it",
+	}, c.Warnings())
+}
+
+func TestCheck_Resource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"resource GameTime { number elapsed = 0; } system Clock { uses GameTime }", ""},
+		{`resource GameTime { number elapsed = "0"; }`, "resource GameTime: field 'elapsed': expected number, got string"},
+		{"component GameTime { } resource GameTime { }", "resource GameTime collides with another declaration of the same name"},
+		{"resource GameTime { } resource GameTime { }", "resource GameTime collides with another declaration of the same name"},
+		{"resource GameTime { number get; }", "resource GameTime: field name 'get' collides with a generated function"},
+		{"system Clock { uses GameTime }", "system Clock: uses unknown resource GameTime"},
+		{"resource GameTime { } system Clock { uses GameTime params { number GameTime; } }", "system Clock: resource GameTime collides with the parameter of the same name"},
+	}
+	for _, tt := range tests {
+		program, err := parser.New(tt.input).ParseProgram()
+		if err != nil {
+			t.Fatalf("ParseProgram error: %v", err)
+		}
+		err = New(nil).Check(program)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("Check(%s) unexpected error: %v", tt.input, err)
+			}
+		} else if err == nil || err.Error() != tt.expected {
+			t.Errorf("Check(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCheck_Prefab(t *testing.T) {
+	components := `component Health { number current = 100; string label; }
+		component Tag { }
+		`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"prefab P { Health { current = 50 }; Tag; }", ""},
+		{"prefab P { Nope; }", "prefab P: unknown component Nope"},
+		{"prefab P { Tag; Tag; }", "prefab P: duplicate component Tag"},
+		{"prefab P { Health { max = 5 }; }", "prefab P: component Health has no field 'max'"},
+		{"prefab P { Health { current = 1, current = 2 }; }", "prefab P: Health.current is overridden twice"},
+		{`prefab P { Health { current = "full" }; }`, "prefab P: Health.current: expected number, got string"},
+		{"prefab P { Tag; } prefab P { Health; }", "prefab P is declared more than once"},
+		{"prefab P extends Base { Tag; }", "prefab P: extends unknown prefab Base"},
+		{"prefab A extends B { } prefab B extends C { } prefab C extends B { }", "prefab inheritance cycle: B -> C -> B"},
+	}
+	for _, tt := range tests {
+		program, err := parser.New(components + tt.input).ParseProgram()
+		if err != nil {
+			t.Fatalf("ParseProgram error: %v", err)
+		}
+		err = New(nil).Check(program)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("Check(%s) unexpected error: %v", tt.input, err)
+			}
+		} else if err == nil || err.Error() != tt.expected {
+			t.Errorf("Check(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCheck_Observer(t *testing.T) {
+	components := `component Health { number current = 100; Vector3 lastHit; }
+		component Mana { number current = 100; }
+		component Lives { int count = 3; }
+		`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"observer O { on changed(Health) where Health.current <= 0 && Health.lastHit.Magnitude > 1 { } }", ""},
+		{"observer O { on changed(Lives) where Lives.count <= 0 || Lives.count - 1 == 0 { } }", ""},
+		{`observer O { on changed(Lives) where Lives.count == "none" { } }`, "observer O: where: cannot compare number and string"},
+		{"observer O { on removed(Health) { } }", ""},
+		{"observer O { on added(Nope) { } }", "observer O: unknown component Nope"},
+		{"observer O { on changed(Health) where Health.max <= 0 { } }", "observer O: where: component Health has no field 'max'"},
+		{"observer O { on changed(Health) where Mana.current <= 0 { } }", "observer O: where: component Mana is not available here"},
+		{"observer O { on changed(Health) where Health.current + 1 { } }", "observer O: where: expected boolean, got number"},
+		{"observer O { on added(Health) { } } observer O { on removed(Mana) { } }", "observer O is declared more than once"},
+	}
+	for _, tt := range tests {
+		program, err := parser.New(components + tt.input).ParseProgram()
+		if err != nil {
+			t.Fatalf("ParseProgram error: %v", err)
+		}
+		err = New(nil).Check(program)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("Check(%s) unexpected error: %v", tt.input, err)
+			}
+		} else if err == nil || err.Error() != tt.expected {
+			t.Errorf("Check(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestCheck_QueryWhere(t *testing.T) {
+	components := `component CharacterAnimation { Instance? animator; string currentAnim = "idle"; }
+		component Health { number current = 100; }
+		component Ammo { int count = 10; float spread = 0.5; }
+		`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`system S { query(CharacterAnimation, Health) where CharacterAnimation.animator != nil && Health.current > 0 { } }`, ""},
+		{`system S { query(Ammo) where Ammo.count > 0 && Ammo.spread * 2 <= 1 { } }`, ""},
+		{`system S { query(Ammo, Health) where -Ammo.count < Health.current && Ammo.count * 2 == Health.current { } }`, ""},
+		{`system S { query(Ammo) where Ammo.count > "10" { } }`, "system S: where: cannot apply > to number and string"},
+		{`system S { query(Ammo) where Ammo.spread == "wide" { } }`, "system S: where: cannot compare number and string"},
+		{`system S { query(CharacterAnimation) where CharacterAnimation.currentAnim < 1 { } }`, "system S: where: cannot apply < to string and number"},
+		{`system S { query(CharacterAnimation) where CharacterAnimation.speed > 1 { } }`, "system S: where: component CharacterAnimation has no field 'speed'"},
+		{`system S { query(CharacterAnimation) where Health.current > 0 { } }`, "system S: where: component Health is not available here"},
+		{`system S { query(Health) where Health.current + nil { } }`, "system S: where: cannot apply + to number and nil"},
+		{`component Target { Instance? part = nil; }`, ""},
+		{`component Target { Instance part = nil; }`, "component Target: field 'part': only optional fields can default to nil"},
+		{`system S { params { number speed = nil; } }`, "system S: parameter 'speed': expected number, got nil"},
+	}
+	for _, tt := range tests {
+		program, err := parser.New(components + tt.input).ParseProgram()
+		if err != nil {
+			t.Fatalf("ParseProgram error: %v", err)
+		}
+		err = New(nil).Check(program)
+		if tt.expected == "" {
+			if err != nil {
+				t.Errorf("Check(%s) unexpected error: %v", tt.input, err)
+			}
+		} else if err == nil || err.Error() != tt.expected {
+			t.Errorf("Check(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// checkDeclarations checks how the declarations of program refer to each
+// other: names declared twice and references to undeclared declarations
+func (c *Checker) checkDeclarations(program *ast.Program) error {
+	if err := c.checkEvents(program); err != nil {
+		return err
+	}
+	if err := checkResources(program); err != nil {
+		return err
+	}
+	if err := checkPrefabs(program); err != nil {
+		return err
+	}
+	return checkObservers(program)
+}
+
+// checkEvents validates event declarations and the emits clauses of systems.
+// Events that are never emitted or never read are reported as warnings, since
+// code outside the schema may emit or read them.
+func (c *Checker) checkEvents(program *ast.Program) error {
+	declared := make(map[string]bool)
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok {
+			declared[comp.Name] = true
+		}
+	}
+	var events []*ast.Event
+	for _, stmt := range program.Statements {
+		event, ok := stmt.(*ast.Event)
+		if !ok {
+			continue
+		}
+		if declared[event.Name] {
+			return fmt.Errorf("event %s collides with another component or event of the same name", event.Name)
+		}
+		declared[event.Name] = true
+		events = append(events, event)
+	}
+
+	emitted := make(map[string]bool)
+	read := make(map[string]bool)
+	for _, stmt := range program.Statements {
+		system, ok := stmt.(*ast.System)
+		if !ok {
+			continue
+		}
+		for _, name := range system.Emits {
+			if !isEvent(events, name) {
+				return fmt.Errorf("system %s: emits unknown event %s", system.Name, name)
+			}
+			emitted[name] = true
+		}
+		for _, name := range system.Reads {
+			read[name] = true
+		}
+	}
+
+	for _, event := range events {
+		switch {
+		case !emitted[event.Name] && read[event.Name]:
+			c.warnings = append(c.warnings, fmt.Sprintf("event %s is read but no system emits it", event.Name))
+		case emitted[event.Name] && !read[event.Name]:
+			c.warnings = append(c.warnings, fmt.Sprintf("event %s is emitted but no system reads it", event.Name))
+		}
+	}
+	return nil
+}
+
+// isEvent reports whether events declares name
+func isEvent(events []*ast.Event, name string) bool {
+	for _, event := range events {
+		if event.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceMembers are generated on every Module.Resources.<Name> table, so
+// resource fields can't use these names
+var resourceMembers = []string{"new", "get", "set"}
+
+// checkResources validates resource declarations and the uses clauses of
+// systems. Resources are passed to callbacks after the parameters, so their
+// names must not shadow a parameter.
+func checkResources(program *ast.Program) error {
+	declared := make(map[string]bool)
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			declared[n.Name] = true
+		case *ast.Event:
+			declared[n.Name] = true
+		}
+	}
+	names := make(map[string]bool)
+	for _, stmt := range program.Statements {
+		resource, ok := stmt.(*ast.Resource)
+		if !ok {
+			continue
+		}
+		if declared[resource.Name] || names[resource.Name] {
+			return fmt.Errorf("resource %s collides with another declaration of the same name", resource.Name)
+		}
+		names[resource.Name] = true
+		for _, field := range resource.Fields {
+			for _, member := range resourceMembers {
+				if field.Name == member {
+					return fmt.Errorf("resource %s: field name '%s' collides with a generated function", resource.Name, field.Name)
+				}
+			}
+		}
+	}
+
+	for _, stmt := range program.Statements {
+		system, ok := stmt.(*ast.System)
+		if !ok {
+			continue
+		}
+		for _, name := range system.Uses {
+			if !names[name] {
+				return fmt.Errorf("system %s: uses unknown resource %s", system.Name, name)
+			}
+			for _, param := range system.Parameters {
+				if param.Name == name {
+					return fmt.Errorf("system %s: resource %s collides with the parameter of the same name", system.Name, name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPrefabs rejects prefabs declared twice and checks that every extends
+// names a prefab without closing a cycle
+func checkPrefabs(program *ast.Program) error {
+	byName := make(map[string]*ast.Prefab)
+	var prefabs []*ast.Prefab
+	for _, stmt := range program.Statements {
+		prefab, ok := stmt.(*ast.Prefab)
+		if !ok {
+			continue
+		}
+		if _, ok := byName[prefab.Name]; ok {
+			return fmt.Errorf("prefab %s is declared more than once", prefab.Name)
+		}
+		byName[prefab.Name] = prefab
+		prefabs = append(prefabs, prefab)
+	}
+
+	for _, prefab := range prefabs {
+		path := []string{prefab.Name}
+		for current := prefab; current.Extends != ""; {
+			base, ok := byName[current.Extends]
+			if !ok {
+				return fmt.Errorf("prefab %s: extends unknown prefab %s", current.Name, current.Extends)
+			}
+			for i, name := range path {
+				if name == base.Name {
+					return fmt.Errorf("prefab inheritance cycle: %s -> %s", strings.Join(path[i:], " -> "), base.Name)
+				}
+			}
+			path = append(path, base.Name)
+			current = base
+		}
+	}
+	return nil
+}
+
+// checkObservers rejects observers declared twice
+func checkObservers(program *ast.Program) error {
+	names := make(map[string]bool)
+	for _, stmt := range program.Statements {
+		observer, ok := stmt.(*ast.Observer)
+		if !ok {
+			continue
+		}
+		if names[observer.Name] {
+			return fmt.Errorf("observer %s is declared more than once", observer.Name)
+		}
+		names[observer.Name] = true
+	}
+	return nil
+}
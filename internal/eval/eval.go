@@ -0,0 +1,227 @@
+// Package eval folds constant expressions at compile time. It understands
+// number, string and boolean literals, references to `const` declarations and
+// the arithmetic, comparison, logical and concatenation operators. Anything
+// else (calls, member access, unknown identifiers) is reported as
+// ErrNotConstant so callers can fall back to emitting the expression as is.
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// ErrNotConstant is returned for expressions that cannot be evaluated at
+// compile time
+var ErrNotConstant = errors.New("not a compile-time constant")
+
+// Kind is the type of a constant value
+type Kind int
+
+const (
+	Number Kind = iota
+	String
+	Boolean
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Number:
+		return "number"
+	case String:
+		return "string"
+	default:
+		return "boolean"
+	}
+}
+
+// Value is the result of evaluating a constant expression
+type Value struct {
+	Kind   Kind
+	Number float64
+	Str    string
+	Bool   bool
+}
+
+// NumberValue, StringValue and BoolValue build Values of each kind
+func NumberValue(n float64) Value { return Value{Kind: Number, Number: n} }
+func StringValue(s string) Value  { return Value{Kind: String, Str: s} }
+func BoolValue(b bool) Value      { return Value{Kind: Boolean, Bool: b} }
+
+// String formats the value the way Luau's tostring would
+func (v Value) String() string {
+	switch v.Kind {
+	case Number:
+		return formatNumber(v.Number)
+	case String:
+		return v.Str
+	default:
+		return strconv.FormatBool(v.Bool)
+	}
+}
+
+// Luau returns the value as a Luau literal
+func (v Value) Luau() string {
+	if v.Kind == String {
+		return fmt.Sprintf("%q", v.Str)
+	}
+	return v.String()
+}
+
+// IsInteger reports whether the value is a whole number
+func (v Value) IsInteger() bool {
+	return v.Kind == Number && v.Number == math.Trunc(v.Number) && !math.IsInf(v.Number, 0)
+}
+
+func formatNumber(n float64) string {
+	switch {
+	case math.IsInf(n, 1):
+		return "math.huge"
+	case math.IsInf(n, -1):
+		return "-math.huge"
+	}
+	return strconv.FormatFloat(n, 'g', -1, 64)
+}
+
+// Eval evaluates expr, resolving identifiers against consts
+func Eval(expr ast.Expression, consts map[string]Value) (Value, error) {
+	switch e := expr.(type) {
+	case *ast.NumberLiteral:
+		n, err := strconv.ParseFloat(e.Value, 64)
+		if err != nil {
+			return Value{}, fmt.Errorf("invalid number %q", e.Value)
+		}
+		return NumberValue(n), nil
+	case *ast.StringLiteral:
+		return StringValue(e.Value), nil
+	case *ast.BooleanLiteral:
+		return BoolValue(e.Value), nil
+	case *ast.Identifier:
+		if v, ok := consts[e.Value]; ok {
+			return v, nil
+		}
+		return Value{}, ErrNotConstant
+	case *ast.PrefixExpression:
+		right, err := Eval(e.Right, consts)
+		if err != nil {
+			return Value{}, err
+		}
+		return evalPrefix(e.Operator, right)
+	case *ast.InfixExpression:
+		left, err := Eval(e.Left, consts)
+		if err != nil {
+			return Value{}, err
+		}
+		right, err := Eval(e.Right, consts)
+		if err != nil {
+			return Value{}, err
+		}
+		return evalInfix(e.Operator, left, right)
+	default:
+		return Value{}, ErrNotConstant
+	}
+}
+
+func evalPrefix(op string, right Value) (Value, error) {
+	switch op {
+	case "-":
+		if right.Kind != Number {
+			return Value{}, fmt.Errorf("cannot negate %s", right.Kind)
+		}
+		return NumberValue(-right.Number), nil
+	case "!":
+		if right.Kind != Boolean {
+			return Value{}, fmt.Errorf("cannot apply ! to %s", right.Kind)
+		}
+		return BoolValue(!right.Bool), nil
+	}
+	return Value{}, fmt.Errorf("unknown prefix operator %s", op)
+}
+
+func evalInfix(op string, left, right Value) (Value, error) {
+	typeError := func() (Value, error) {
+		return Value{}, fmt.Errorf("cannot apply %s to %s and %s", op, left.Kind, right.Kind)
+	}
+
+	switch op {
+	case "+", "-", "*", "/":
+		if left.Kind != Number || right.Kind != Number {
+			return typeError()
+		}
+		a, b := left.Number, right.Number
+		var n float64
+		switch op {
+		case "+":
+			n = a + b
+		case "-":
+			n = a - b
+		case "*":
+			n = a * b
+		default:
+			if b == 0 {
+				return Value{}, errors.New("division by zero")
+			}
+			n = a / b
+		}
+		// NaN has no Luau literal, and comparisons with it are always false
+		if math.IsNaN(n) {
+			return Value{}, fmt.Errorf("%s %s %s is not a number", formatNumber(a), op, formatNumber(b))
+		}
+		return NumberValue(n), nil
+	case "..":
+		// Luau converts numbers to strings when concatenating
+		if left.Kind == Boolean || right.Kind == Boolean {
+			return typeError()
+		}
+		return StringValue(left.String() + right.String()), nil
+	case "==", "!=":
+		equal := left == right
+		if op == "!=" {
+			equal = !equal
+		}
+		return BoolValue(equal), nil
+	case "<", ">", "<=", ">=":
+		var cmp int
+		switch {
+		case left.Kind == Number && right.Kind == Number:
+			cmp = compare(left.Number, right.Number)
+		case left.Kind == String && right.Kind == String:
+			cmp = compare(left.Str, right.Str)
+		default:
+			return typeError()
+		}
+		switch op {
+		case "<":
+			return BoolValue(cmp < 0), nil
+		case ">":
+			return BoolValue(cmp > 0), nil
+		case "<=":
+			return BoolValue(cmp <= 0), nil
+		default:
+			return BoolValue(cmp >= 0), nil
+		}
+	case "&&", "||":
+		if left.Kind != Boolean || right.Kind != Boolean {
+			return typeError()
+		}
+		if op == "&&" {
+			return BoolValue(left.Bool && right.Bool), nil
+		}
+		return BoolValue(left.Bool || right.Bool), nil
+	}
+	return Value{}, fmt.Errorf("unknown operator %s", op)
+}
+
+func compare[T float64 | string](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,94 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/parser"
+)
+
+// parseValue parses input as the default value of a field
+func parseValue(t *testing.T, input string) ast.Expression {
+	t.Helper()
+	program, err := parser.New(fmt.Sprintf("component T { any v = %s; }", input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	return program.Statements[0].(*ast.Component).Fields[0].DefaultValue
+}
+
+func TestEval(t *testing.T) {
+	consts := map[string]Value{
+		"GRAVITY": NumberValue(9.81),
+		"NAME":    StringValue("Bob"),
+		"DEBUG":   BoolValue(false),
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"16 * 1.5", "24"},
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"10 - 4 - 3", "3"},
+		{"7 / 2", "3.5"},
+		{"-GRAVITY * 2", "-19.62"},
+		{"0.1 + 0.2", "0.30000000000000004"},
+		{`NAME .. " Lv " .. 3`, `"Bob Lv 3"`},
+		{"!DEBUG && 1 < 2", "true"},
+		{"DEBUG || GRAVITY >= 10", "false"},
+		{`"a" < "b"`, "true"},
+		{`NAME == "Bob"`, "true"},
+		{`1 != "1"`, "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			value, err := Eval(parseValue(t, tt.input), consts)
+			if err != nil {
+				t.Fatalf("Eval() error = %v", err)
+			}
+			if value.Luau() != tt.expected {
+				t.Errorf("Eval() wrong. expected=%s, got=%s", tt.expected, value.Luau())
+			}
+		})
+	}
+}
+
+func TestEval_Errors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "division by zero"},
+		{"(2 - 2) / (1 - 1)", "division by zero"},
+		{"HUGE - HUGE", "math.huge - math.huge is not a number"},
+		{"HUGE * 0", "math.huge * 0 is not a number"},
+		{"-HUGE / HUGE", "-math.huge / math.huge is not a number"},
+		{`1 + "a"`, "cannot apply + to number and string"},
+		{"-true", "cannot negate boolean"},
+		{"!1", "cannot apply ! to number"},
+		{"1 && true", "cannot apply && to number and boolean"},
+		{`true .. "a"`, "cannot apply .. to boolean and string"},
+		{`1 < "a"`, "cannot apply < to number and string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Eval(parseValue(t, tt.input), map[string]Value{"HUGE": NumberValue(math.Inf(1))})
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("Eval() error wrong. expected=%q, got=%v", tt.expected, err)
+			}
+		})
+	}
+
+	for _, input := range []string{"UNKNOWN", "Vector3.new(1, 2, 3)", "math.pi * 2", "{ 1, 2 }"} {
+		if _, err := Eval(parseValue(t, input), nil); !errors.Is(err, ErrNotConstant) {
+			t.Errorf("Eval(%s) expected ErrNotConstant, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// argName returns the callback argument a component is passed as:
+// CharacterInput is characterInput, UIState is uiState and HP is hp
+func argName(component string) string {
+	runes := []rune(component)
+	upper := 0
+	for upper < len(runes) && unicode.IsUpper(runes[upper]) {
+		upper++
+	}
+	lower := upper
+	if upper > 1 && upper < len(runes) {
+		lower = upper - 1 // The last capital starts the next word
+	}
+	for i := 0; i < lower; i++ {
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// queryMethod returns the world method of the selected library that iterates
+// the entities having every given component, yielding the entity then each
+// component in order
+func (g *Generator) queryMethod() string {
+	if g.library == LibraryECR {
+		return "view"
+	}
+	return "query"
+}
+
+// createMethod returns the world method of the selected library that creates
+// an entity
+func (g *Generator) createMethod() string {
+	if g.library == LibraryECR {
+		return "create"
+	}
+	return "entity"
+}
+
+// queryCall returns the call of queryMethod on world for components
+func (g *Generator) queryCall(world string, components []string) string {
+	ids := make([]string, len(components))
+	for i, comp := range components {
+		ids[i] = componentID(comp)
+	}
+	return fmt.Sprintf("%s:%s(%s)", world, g.queryMethod(), strings.Join(ids, ", "))
+}
+
+// generateCallback emits the callback of a system and its run function. The
+// callback takes the entity and its components in query order, then the
+// parameters and resources. run(world, params) iterates the query with the
+// selected library and calls the callback for each entity meeting the query's
+// where clause; systems without a query are called once.
+func (g *Generator) generateCallback(system *ast.System) error {
+	var terms []string
+	if system.Query != nil {
+		terms = system.Query.Components
+	}
+
+	var params, args []string
+	passed := make(map[string]string)
+	pass := func(name, annotation, what string) error {
+		if other, ok := passed[name]; ok {
+			return fmt.Errorf("system %s: %s and %s are both passed to the callback as '%s'", system.Name, other, what, name)
+		}
+		passed[name] = what
+		params = append(params, name+": "+annotation)
+		args = append(args, name)
+		return nil
+	}
+	if len(terms) > 0 {
+		if err := pass("entity", "Entity", "the entity"); err != nil {
+			return err
+		}
+	}
+	for _, comp := range terms {
+		if err := pass(argName(comp), comp, "component "+comp); err != nil {
+			return err
+		}
+	}
+	for _, param := range system.Parameters {
+		if err := pass(param.Name, luauType(param.Type), "parameter "+param.Name); err != nil {
+			return err
+		}
+	}
+	for _, resource := range system.Uses {
+		if err := pass(resource, resource, "resource "+resource); err != nil {
+			return err
+		}
+	}
+
+	g.writeCode("callback = function("+strings.Join(params, ", ")+")", system.Code)
+	g.writeLine("end,")
+
+	path := "Module.Systems." + system.Name
+	g.writeLine("run = function(world: any, params: { [string]: any }?)")
+	g.indent++
+	if len(system.Parameters) > 0 {
+		g.writeLine("local values = params or {}")
+		for _, param := range system.Parameters {
+			g.writeLine(fmt.Sprintf("local %s = if values.%s ~= nil then values.%s else %s.parameters.%s", param.Name, param.Name, param.Name, path, param.Name))
+		}
+	}
+	for _, resource := range system.Uses {
+		g.writeLine(fmt.Sprintf("local %s = Module.Resources.%s.get(world)", resource, resource))
+	}
+	if len(terms) == 0 {
+		g.writeLine(fmt.Sprintf("%s.callback(%s)", path, strings.Join(args, ", ")))
+	} else {
+		g.writeLine(fmt.Sprintf("local callback = %s.callback", path))
+		g.writeLine(fmt.Sprintf("for %s in %s do", strings.Join(args[:len(terms)+1], ", "), g.queryCall("world", terms)))
+		g.indent++
+		if system.Query.Where != nil {
+			if err := g.writeGuard(system.Query.Where, terms, "continue"); err != nil {
+				return fmt.Errorf("system %s: where: %v", system.Name, err)
+			}
+		}
+		g.writeLine(fmt.Sprintf("callback(%s)", strings.Join(args, ", ")))
+		g.indent--
+		g.writeLine("end")
+	}
+	g.indent--
+	g.writeLine("end,")
+	return nil
+}
+
+// writeCode emits the header of a function followed by code, indented one
+// level. The caller closes the function.
+func (g *Generator) writeCode(header, code string) {
+	g.writeLine(header)
+	if strings.TrimSpace(code) == "" {
+		return
+	}
+	g.indent++
+	for _, line := range strings.Split(strings.TrimSpace(code), "\n") {
+		g.writeLine(strings.TrimRight(line, " \t"))
+	}
+	g.indent--
+}
+
+// writeGuard emits `if not (where) then exit end`. The components of where
+// are read from the callback arguments named by argName.
+func (g *Generator) writeGuard(where ast.Expression, components []string, exit string) error {
+	g.bindings = make(map[string]string, len(components))
+	defer func() { g.bindings = nil }()
+	for _, comp := range components {
+		g.bindings[comp] = argName(comp)
+	}
+	condition, err := g.generateExpression(where)
+	if err != nil {
+		return err
+	}
+	g.writeLine(fmt.Sprintf("if not (%s) then", condition))
+	g.indent++
+	g.writeLine(exit)
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
@@ -0,0 +1,137 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/eval"
+)
+
+// evaluateConstants folds every const declaration in order. A const may refer
+// to consts declared before it.
+func (g *Generator) evaluateConstants(program *ast.Program) error {
+	g.constants = map[string]eval.Value{}
+	g.constOrder = nil
+
+	for _, stmt := range program.Statements {
+		c, ok := stmt.(*ast.Const)
+		if !ok {
+			continue
+		}
+		if _, exists := g.constants[c.Name]; exists {
+			return fmt.Errorf("const %s is declared more than once", c.Name)
+		}
+		value, err := eval.Eval(c.Value, g.constants)
+		if errors.Is(err, eval.ErrNotConstant) {
+			return fmt.Errorf("const %s: %s is not a compile-time constant", c.Name, c.Value.String())
+		}
+		if err != nil {
+			return fmt.Errorf("const %s: %v", c.Name, err)
+		}
+		if err := checkConstType(c, value); err != nil {
+			return err
+		}
+		g.constants[c.Name] = value
+		g.constOrder = append(g.constOrder, c.Name)
+	}
+	return nil
+}
+
+// checkConstType verifies a folded value against the declared const type
+func checkConstType(c *ast.Const, value eval.Value) error {
+	var ok bool
+	switch c.Type {
+	case "":
+		return nil
+	case "number", "float":
+		ok = value.Kind == eval.Number
+	case "int":
+		ok = value.IsInteger()
+	case "string":
+		ok = value.Kind == eval.String
+	case "boolean":
+		ok = value.Kind == eval.Boolean
+	default:
+		return fmt.Errorf("const %s: unsupported type %s (expected number, int, float, string or boolean)", c.Name, c.Type)
+	}
+	if !ok {
+		return fmt.Errorf("const %s: expected %s, got %s %s", c.Name, c.Type, value.Kind, value.Luau())
+	}
+	return nil
+}
+
+// checkConstantExpressions reports folding errors such as division by zero in
+// default values, attribute arguments and parameters, which would otherwise
+// only surface as comments in the generated code.
+func (g *Generator) checkConstantExpressions(program *ast.Program) error {
+	check := func(context string, expr ast.Expression) error {
+		if expr == nil {
+			return nil
+		}
+		if _, err := g.generateExpression(expr); err != nil {
+			return fmt.Errorf("%s: %v", context, err)
+		}
+		return nil
+	}
+
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			for _, field := range n.Fields {
+				context := fmt.Sprintf("component %s: field '%s'", n.Name, field.Name)
+				if err := check(context, field.DefaultValue); err != nil {
+					return err
+				}
+				for _, attr := range field.Attributes {
+					for _, arg := range attr.Arguments {
+						if err := check(context+": @"+attr.Name, arg); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		case *ast.System:
+			for _, param := range n.Parameters {
+				if err := check(fmt.Sprintf("system %s: parameter '%s'", n.Name, param.Name), param.DefaultValue); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// foldExpression returns the Luau literal for a constant operator expression
+// or const reference. ok is false when expr is not fully constant.
+func (g *Generator) foldExpression(expr ast.Expression) (string, bool, error) {
+	switch expr.(type) {
+	case *ast.InfixExpression, *ast.PrefixExpression, *ast.Identifier:
+	default:
+		return "", false, nil // Literals are emitted as written
+	}
+	value, err := eval.Eval(expr, g.constants)
+	if errors.Is(err, eval.ErrNotConstant) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value.Luau(), true, nil
+}
+
+// writeConstants emits Module.Constants so hand-written system code can use
+// the same values as the schema
+func (g *Generator) writeConstants() {
+	if len(g.constOrder) == 0 {
+		return
+	}
+	g.writeLine("Module.Constants = {")
+	g.indent++
+	for _, name := range g.constOrder {
+		g.writeLine(fmt.Sprintf("%s = %s,", name, g.constants[name].Luau()))
+	}
+	g.indent--
+	g.writeLine("}")
+	g.writeLine("")
+}
@@ -0,0 +1,146 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// componentMembers lists the functions the generator attaches to every
+// Module.Components entry. Fields may not use these names.
+var componentMembers = map[string]bool{
+	"new":      true,
+	"validate": true,
+}
+
+// hasComponents reports whether the program declares at least one component,
+// other than a generic template, or resource, in which case the shared
+// constructor helpers must be emitted.
+func hasComponents(program *ast.Program) bool {
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			if !n.IsGeneric() {
+				return true
+			}
+		case *ast.Resource:
+			return true
+		}
+	}
+	return false
+}
+
+// writeComponentPrelude emits the module-level helpers used by the generated
+// constructors. It has to run before the first component so the locals are in
+// scope for every constructor.
+func (g *Generator) writeComponentPrelude() {
+	// Strict mode makes constructors reject override keys that are not fields
+	g.writeLine("Module.Strict = false")
+	g.writeLine("")
+	g.writeLine("local function deepCopy(value: any): any")
+	g.indent++
+	g.writeLine(`if type(value) ~= "table" then`)
+	g.indent++
+	g.writeLine("return value")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("local copy = {}")
+	g.writeLine("for key, inner in pairs(value) do")
+	g.indent++
+	g.writeLine("copy[key] = deepCopy(inner)")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("return copy")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("")
+	g.writeLine("local function checkOverrides(component: string, overrides: { [string]: any }, fields: { [string]: boolean })")
+	g.indent++
+	g.writeLine("for key in pairs(overrides) do")
+	g.indent++
+	g.writeLine("if not fields[key] then")
+	g.indent++
+	g.writeLine(`error(string.format("%s.new: unknown field %q", component, tostring(key)), 3)`)
+	g.indent--
+	g.writeLine("end")
+	g.indent--
+	g.writeLine("end")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("")
+}
+
+// generateComponentType emits the exported Luau type describing a component
+func (g *Generator) generateComponentType(comp *ast.Component) {
+	if comp.Extends != "" {
+		g.writeLine(fmt.Sprintf("-- %s extends %s and has all of its fields", comp.Name, comp.Extends))
+	}
+	if len(comp.Fields) == 0 {
+		g.writeLine(fmt.Sprintf("export type %s = {}", comp.Name))
+		return
+	}
+	g.writeLine(fmt.Sprintf("export type %s = {", comp.Name))
+	g.indent++
+	for _, field := range comp.Fields {
+		g.writeLine(fmt.Sprintf("%s: %s,", field.Name, fieldLuauType(field)))
+	}
+	g.indent--
+	g.writeLine("}")
+}
+
+// generateConstructor emits Module.Components.<Name>.new(overrides). Omitted
+// fields fall back to their default value; table defaults are deep-copied so
+// entities never share a mutable table.
+func (g *Generator) generateConstructor(comp *ast.Component) {
+	g.generateConstructorAt("Module.Components."+comp.Name, comp)
+}
+
+// generateConstructorAt emits the new(overrides) constructor of comp on the
+// table at path, which holds the default values
+func (g *Generator) generateConstructorAt(path string, comp *ast.Component) {
+	fieldSet := make([]string, 0, len(comp.Fields))
+	for _, field := range comp.Fields {
+		fieldSet = append(fieldSet, field.Name+" = true")
+	}
+
+	g.writeLine(fmt.Sprintf("function %s.new(overrides: { [string]: any }?): %s", path, comp.Name))
+	g.indent++
+	g.writeLine("local values = overrides or {}")
+	g.writeLine("if Module.Strict then")
+	g.indent++
+	g.writeLine(fmt.Sprintf("checkOverrides(%q, values, { %s })", comp.Name, strings.Join(fieldSet, ", ")))
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("return {")
+	g.indent++
+	for _, field := range comp.Fields {
+		g.writeLine(fmt.Sprintf("%s = %s,", field.Name, g.constructorValue(path, field)))
+	}
+	g.indent--
+	g.writeLine("}")
+	g.indent--
+	g.writeLine("end")
+}
+
+// constructorValue returns the expression a constructor uses for one field
+func (g *Generator) constructorValue(path string, field *ast.Field) string {
+	override := "values." + field.Name
+	if isTableField(field) {
+		return fmt.Sprintf("if %s ~= nil then %s else deepCopy(%s.%s)", override, override, path, field.Name)
+	}
+	defaultValueStr := g.getDefaultValue(field.DefaultValue, field.Type, field.Optional)
+	if defaultValueStr == "nil" {
+		return override
+	}
+	return fmt.Sprintf("if %s ~= nil then %s else %s", override, override, defaultValueStr)
+}
+
+// isTableField reports whether a field's default value is a Luau table
+func isTableField(field *ast.Field) bool {
+	if field.Type == "table" {
+		return true
+	}
+	_, ok := field.DefaultValue.(*ast.TableConstructor)
+	return ok
+}
@@ -0,0 +1,284 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/wire"
+)
+
+// deltaMembers are the dirty-tracking members added to @replicated components
+var deltaMembers = []string{"Dirty", "DirtyAll", "diff", "deltaSize", "serializeDelta", "applyDelta"}
+
+// generateDelta emits the dirty masks and the diff/applyDelta pair for a
+// @replicated component. Bit i of a mask marks the i-th replicated field.
+func (g *Generator) generateDelta(comp *ast.Component) error {
+	layout, err := wire.NewLayout(comp)
+	if err != nil {
+		return err
+	}
+	path := "Module.Components." + comp.Name
+
+	var bits []string
+	var all uint32
+	for i, field := range layout.Fields {
+		bits = append(bits, fmt.Sprintf("%s = %d", field.Name, wire.DirtyBit(i)))
+		all |= wire.DirtyBit(i)
+	}
+	if len(bits) == 0 {
+		g.writeLine(path + ".Dirty = {}")
+	} else {
+		g.writeLine(fmt.Sprintf("%s.Dirty = { %s }", path, strings.Join(bits, ", ")))
+	}
+	g.writeLine(fmt.Sprintf("%s.DirtyAll = %d", path, all))
+	g.writeLine("")
+
+	// diff
+	g.writeLine(fmt.Sprintf("function %s.diff(old: %s, new: %s): number", path, comp.Name, comp.Name))
+	g.indent++
+	g.writeLine("local mask = 0")
+	for i, field := range layout.Fields {
+		g.writeLine(fmt.Sprintf("if old.%s ~= new.%s then", field.Name, field.Name))
+		g.indent++
+		g.writeLine(fmt.Sprintf("mask = bit32.bor(mask, %d)", wire.DirtyBit(i)))
+		g.indent--
+		g.writeLine("end")
+	}
+	g.writeLine("return mask")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("")
+
+	// deltaSize
+	g.writeLine(fmt.Sprintf("function %s.deltaSize(component: %s, mask: number): number", path, comp.Name))
+	g.indent++
+	g.writeLine(fmt.Sprintf("local size = %d", layout.MaskSize()))
+	for i, field := range layout.Fields {
+		g.writeLine(fmt.Sprintf("if bit32.btest(mask, %d) then", wire.DirtyBit(i)))
+		g.indent++
+		g.writeLine("size += " + deltaFieldSize("component."+field.Name, field))
+		g.indent--
+		g.writeLine("end")
+	}
+	g.writeLine("return size")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("")
+
+	// serializeDelta
+	maskType := headerType(layout.MaskSize())
+	g.writeLine(fmt.Sprintf("function %s.serializeDelta(component: %s, mask: number, buf: buffer, offset: number): number", path, comp.Name))
+	g.indent++
+	g.writeLine(fmt.Sprintf("buffer.write%s(buf, offset, mask)", maskType))
+	g.writeLine(fmt.Sprintf("offset += %d", layout.MaskSize()))
+	for i, field := range layout.Fields {
+		access := "component." + field.Name
+		g.writeLine(fmt.Sprintf("if bit32.btest(mask, %d) then", wire.DirtyBit(i)))
+		g.indent++
+		if field.Optional {
+			g.writeLine(fmt.Sprintf("buffer.writeu8(buf, offset, if %s ~= nil then 1 else 0)", access))
+			g.writeLine("offset += 1")
+			g.writeLine(fmt.Sprintf("if %s ~= nil then", access))
+			g.indent++
+		}
+		if field.Kind == wire.Bool {
+			g.writeLine(fmt.Sprintf("buffer.writeu8(buf, offset, if %s then 1 else 0)", access))
+			g.writeLine("offset += 1")
+		} else {
+			g.writeFieldWrite(access, field.Kind)
+		}
+		if field.Optional {
+			g.indent--
+			g.writeLine("end")
+		}
+		g.indent--
+		g.writeLine("end")
+	}
+	g.writeLine("return offset")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("")
+
+	// applyDelta
+	g.writeLine(fmt.Sprintf("function %s.applyDelta(component: %s, buf: buffer, offset: number): number", path, comp.Name))
+	g.indent++
+	g.writeLine(fmt.Sprintf("local mask = buffer.read%s(buf, offset)", maskType))
+	g.writeLine(fmt.Sprintf("offset += %d", layout.MaskSize()))
+	for i, field := range layout.Fields {
+		target := "component." + field.Name
+		g.writeLine(fmt.Sprintf("if bit32.btest(mask, %d) then", wire.DirtyBit(i)))
+		g.indent++
+		if field.Optional {
+			g.writeLine("local present = buffer.readu8(buf, offset) == 1")
+			g.writeLine("offset += 1")
+			g.writeLine("if present then")
+			g.indent++
+		}
+		if field.Kind == wire.Bool {
+			g.writeLine(fmt.Sprintf("%s = buffer.readu8(buf, offset) == 1", target))
+			g.writeLine("offset += 1")
+		} else {
+			g.writeFieldRead(target, field.Kind)
+		}
+		if field.Optional {
+			g.indent--
+			g.writeLine("else")
+			g.indent++
+			g.writeLine(target + " = nil")
+			g.indent--
+			g.writeLine("end")
+		}
+		g.indent--
+		g.writeLine("end")
+	}
+	g.writeLine("return offset")
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
+
+// deltaFieldSize returns the Luau expression for a dirty field's encoded size
+func deltaFieldSize(access string, field *wire.Field) string {
+	size := fmt.Sprintf("%d", field.Kind.Size())
+	if field.Kind == wire.Bool {
+		size = "1"
+	} else if field.Kind == wire.String {
+		size += " + #" + access
+	}
+	if field.Optional {
+		return fmt.Sprintf("1 + (if %s ~= nil then %s else 0)", access, size)
+	}
+	return size
+}
+
+// maxReplicated is the number of @replicated components the u8 component ids
+// of replication buffers can tell apart
+const maxReplicated = 255
+
+// generateReplication emits Module.Replication, which batches the changed
+// @replicated components of one entity into a single buffer, and the system
+// that feeds it every frame.
+func (g *Generator) generateReplication(comps []*ast.Component) {
+	var ids, names []string
+	for i, comp := range comps {
+		ids = append(ids, fmt.Sprintf("%s = %d", comp.Name, i+1))
+		names = append(names, fmt.Sprintf("%q", comp.Name))
+	}
+
+	lines := []string{
+		"Module.Replication = {}",
+		fmt.Sprintf("Module.Replication.ComponentIds = { %s }", strings.Join(ids, ", ")),
+		fmt.Sprintf("Module.Replication.ComponentNames = { %s }", strings.Join(names, ", ")),
+		"Module.Replication.snapshots = {}",
+		"Module.Replication.outgoing = {}",
+		"",
+		"-- Packs every replicated component of an entity that changed since previous",
+		"-- into one buffer: f64 entity id, which holds any JECS or ECR id exactly, u8",
+		"-- component count, then per component a u8 component id followed by its",
+		"-- delta. Returns nil when nothing changed.",
+		"function Module.Replication.encodeEntity(entityId: number, previous: { [string]: any }, current: { [string]: any }): buffer?",
+		"    local size = 9",
+		"    local count = 0",
+		"    local masks = {}",
+		"    for id, name in ipairs(Module.Replication.ComponentNames) do",
+		"        local component = current[name]",
+		"        if component ~= nil then",
+		"            local codec = Module.Components[name]",
+		"            local old = previous[name]",
+		"            local mask = if old == nil then codec.DirtyAll else codec.diff(old, component)",
+		"            if mask ~= 0 then",
+		"                count += 1",
+		"                masks[id] = mask",
+		"                size += 1 + codec.deltaSize(component, mask)",
+		"            end",
+		"        end",
+		"    end",
+		"    if count == 0 then",
+		"        return nil",
+		"    end",
+		"    local buf = buffer.create(size)",
+		"    buffer.writef64(buf, 0, entityId)",
+		"    buffer.writeu8(buf, 8, count)",
+		"    local offset = 9",
+		"    for id, name in ipairs(Module.Replication.ComponentNames) do",
+		"        local mask = masks[id]",
+		"        if mask ~= nil then",
+		"            buffer.writeu8(buf, offset, id)",
+		"            offset = Module.Components[name].serializeDelta(current[name], mask, buf, offset + 1)",
+		"        end",
+		"    end",
+		"    return buf",
+		"end",
+		"",
+		"-- Applies a buffer produced by encodeEntity to components, creating missing",
+		"-- components from their defaults. Returns the entity id.",
+		"function Module.Replication.applyEntity(buf: buffer, components: { [string]: any }): number",
+		"    local entityId = buffer.readf64(buf, 0)",
+		"    local count = buffer.readu8(buf, 8)",
+		"    local offset = 9",
+		"    for _ = 1, count do",
+		"        local name = Module.Replication.ComponentNames[buffer.readu8(buf, offset)]",
+		"        local codec = Module.Components[name]",
+		"        local component = components[name] or codec.new()",
+		"        components[name] = component",
+		"        offset = codec.applyDelta(component, buf, offset + 1)",
+		"    end",
+		"    return entityId",
+		"end",
+		"",
+		"-- Returns the buffers queued since the last flush",
+		"function Module.Replication.flush(): { buffer }",
+		"    local outgoing = Module.Replication.outgoing",
+		"    Module.Replication.outgoing = {}",
+		"    return outgoing",
+		"end",
+		"",
+		"Module.Systems.Replication = {",
+		`    name = "Replication",`,
+		"    callback = function(entity: Entity, components: { [string]: any })",
+		"        local previous = Module.Replication.snapshots[entity] or {}",
+		"        -- A component removed since the snapshot is sent in full when it returns",
+		"        for name in previous do",
+		"            if components[name] == nil then",
+		"                previous[name] = nil",
+		"            end",
+		"        end",
+		"        local buf = Module.Replication.encodeEntity(entity, previous, components)",
+		"        if buf ~= nil then",
+		"            table.insert(Module.Replication.outgoing, buf)",
+		"            local snapshot = {}",
+		"            for _, name in ipairs(Module.Replication.ComponentNames) do",
+		"                local component = components[name]",
+		"                if component ~= nil then",
+		"                    snapshot[name] = table.clone(component)",
+		"                end",
+		"            end",
+		"            Module.Replication.snapshots[entity] = snapshot",
+		"        end",
+		"    end,",
+		"    -- Gathers the replicated components of every entity that has one",
+		"    run = function(world: any)",
+		"        local entities: { [Entity]: { [string]: any } } = {}",
+		"        for _, name in ipairs(Module.Replication.ComponentNames) do",
+		fmt.Sprintf("            for entity, component in world:%s(Module.Ids[name]) do", g.queryMethod()),
+		"                entities[entity] = entities[entity] or {}",
+		"                entities[entity][name] = component",
+		"            end",
+		"        end",
+		"        for entity, components in entities do",
+		"            Module.Systems.Replication.callback(entity, components)",
+		"        end",
+		"        -- Forget entities that were deleted or lost every replicated component",
+		"        for entity in Module.Replication.snapshots do",
+		"            if entities[entity] == nil then",
+		"                Module.Replication.snapshots[entity] = nil",
+		"            end",
+		"        end",
+		"    end",
+		"}",
+	}
+	for _, line := range lines {
+		g.writeLine(line)
+	}
+}
@@ -0,0 +1,109 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// events returns the event declarations of program
+func events(program *ast.Program) []*ast.Event {
+	var out []*ast.Event
+	for _, stmt := range program.Statements {
+		if event, ok := stmt.(*ast.Event); ok {
+			out = append(out, event)
+		}
+	}
+	return out
+}
+
+// eventReaders returns the systems that read event, in declaration order
+func eventReaders(program *ast.Program, event string) []string {
+	var readers []string
+	for _, stmt := range program.Statements {
+		if system, ok := stmt.(*ast.System); ok && containsString(system.Reads, event) {
+			readers = append(readers, system.Name)
+		}
+	}
+	return readers
+}
+
+// generateEvent emits the payload type of an event and its queue
+func (g *Generator) generateEvent(event *ast.Event, readers []string) {
+	g.generateComponentType(&ast.Component{Name: event.Name, Fields: event.Fields})
+	g.writeLine("")
+
+	quoted := make([]string, len(readers))
+	for i, name := range readers {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	list := "{}"
+	if len(quoted) > 0 {
+		list = "{ " + strings.Join(quoted, ", ") + " }"
+	}
+	g.writeLine(fmt.Sprintf("Module.Events.%s = createEvent(%s) :: EventQueue<%s>", event.Name, list, event.Name))
+}
+
+// writeEventPrelude emits Module.Events and the queue type shared by every
+// event. A reader sees each event once: the first iterate call of a system
+// tick returns the events emitted since that system's previous tick, and later
+// calls in the same tick return the same events. Events are dropped once every
+// reader has seen them.
+func (g *Generator) writeEventPrelude() {
+	lines := []string{
+		"Module.Events = {}",
+		"",
+		"export type EventQueue<T> = {",
+		"    Readers: { string },",
+		"    emit: (event: T) -> (),",
+		"    iterate: () -> { T },",
+		"    clear: () -> (),",
+		"}",
+		"",
+		"local function createEvent<T>(readers: { string }): EventQueue<T>",
+		"    local queue: { T } = {}",
+		"    local cursors: { [string]: number } = {}",
+		"    local seen: { [string]: { tick: number, events: { T } } } = {}",
+		"    local event = { Readers = readers }",
+		"",
+		"    function event.emit(value: T)",
+		"        table.insert(queue, value)",
+		"    end",
+		"",
+		"    -- Outside a scheduled system, returns every queued event; call clear when done",
+		"    function event.iterate(): { T }",
+		"        local reader = Module.Scheduler and Module.Scheduler.current",
+		"        if reader == nil then",
+		"            return table.clone(queue)",
+		"        end",
+		"        local last = seen[reader]",
+		"        if last ~= nil and last.tick == Module.Scheduler.ticks then",
+		"            return last.events",
+		"        end",
+		"        local events = table.move(queue, (cursors[reader] or 0) + 1, #queue, 1, {})",
+		"        cursors[reader] = #queue",
+		"        seen[reader] = { tick = Module.Scheduler.ticks, events = events }",
+		"        for _, other in readers do",
+		"            if (cursors[other] or 0) < #queue then",
+		"                return events",
+		"            end",
+		"        end",
+		"        table.clear(queue)",
+		"        table.clear(cursors)",
+		"        return events",
+		"    end",
+		"",
+		"    function event.clear()",
+		"        table.clear(queue)",
+		"        table.clear(cursors)",
+		"    end",
+		"",
+		"    return event :: any",
+		"end",
+		"",
+	}
+	for _, line := range lines {
+		g.writeLine(line)
+	}
+}
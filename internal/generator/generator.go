@@ -5,61 +5,181 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/ejecs/ejecs/internal/analysis"
 	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/checker"
+	"github.com/ejecs/ejecs/internal/eval"
+	"github.com/ejecs/ejecs/internal/robloxapi"
 )
 
-// Config holds the configuration for the generator
-// type Config struct { // Removed Config struct
-// 	Library string // Target library (ecr, jecs)
-// }
+// ECS libraries the generated system loops can query
+const (
+	LibraryJECS = "jecs" // world:query(A, B)
+	LibraryECR  = "ecr"  // registry:view(A, B)
+)
 
 // Generator handles the code generation process
 type Generator struct {
 	buffer bytes.Buffer
 	indent int
+
+	library    string                // LibraryJECS or LibraryECR
+	bindings   map[string]string     // Component names a where clause reads, to their argument names
+	constants  map[string]eval.Value // Folded const declarations
+	constOrder []string              // Const names in declaration order
+	warnings   []string              // Problems that don't stop generation
 }
 
 // New creates a new Generator instance
 // func New(config Config) *Generator { // Old New function signature
 func New() *Generator { // Simplified New function signature
-	return &Generator{}
+	return &Generator{library: LibraryJECS}
+}
+
+// SetLibrary selects the ECS library the generated system loops query
+func (g *Generator) SetLibrary(library string) error {
+	if library != LibraryJECS && library != LibraryECR {
+		return fmt.Errorf("library must be either '%s' or '%s', got '%s'", LibraryECR, LibraryJECS, library)
+	}
+	g.library = library
+	return nil
+}
+
+// Warnings returns the warnings of the last Generate call
+func (g *Generator) Warnings() []string {
+	return g.warnings
 }
 
 // Generate generates the complete Luau module code from the AST Program
 func (g *Generator) Generate(program *ast.Program) (string, error) {
 	g.buffer.Reset()
 	g.indent = 0
+	g.warnings = nil
+
+	if err := g.evaluateConstants(program); err != nil {
+		return "", err
+	}
+	if err := g.checkConstantExpressions(program); err != nil {
+		return "", err
+	}
+	check := checker.New(g.constants)
+	if err := check.Check(program); err != nil {
+		return "", err
+	}
+	g.warnings = append(g.warnings, check.Warnings()...)
+
+	systems, err := g.systemOrder(program)
+	if err != nil {
+		return "", err
+	}
+	report := analysis.Analyze(systems)
+	g.warnUnorderedWrites(systems, report)
+	batches := report.Batches
+	replicated := componentsWithAttribute(program, "replicated")
+	if len(replicated) > maxReplicated {
+		return "", fmt.Errorf("%d components are @replicated, replication buffers have room for %d component ids", len(replicated), maxReplicated)
+	}
+	if len(replicated) > 0 {
+		if _, ok := report.Access["Replication"]; ok {
+			return "", fmt.Errorf("system Replication collides with the generated replication system")
+		}
+		batches = append(batches, []string{"Replication"}) // Sends the changes made by every other system
+	}
 
 	// Write header
 	g.writeHeader()
+	if hasSystems(program) {
+		g.writeLine("Module.Systems = {}")
+		g.writeLine("")
+	}
+	if len(observers(program)) > 0 {
+		g.writeLine("Module.Observers = {}")
+		g.writeLine("")
+	}
+	if hasSystems(program) || len(observers(program)) > 0 {
+		g.writeLine("export type Entity = number")
+		g.writeLine("")
+	}
+	if len(events(program)) > 0 {
+		g.writeEventPrelude()
+	}
+	if len(resources(program)) > 0 {
+		g.writeResourcePrelude()
+	}
+	if len(prefabs(program)) > 0 {
+		g.writePrefabPrelude()
+	}
+	g.writeConstants()
+	if hasComponents(program) {
+		g.writeComponentPrelude()
+	}
+	if persistent := componentsWithAttribute(program, "persistent"); len(persistent) > 0 {
+		g.writeSaveCodecs(persistent)
+	}
 
 	// Process each statement
-	for i, stmt := range program.Statements {
+	first := true
+	for _, stmt := range program.Statements {
+		if _, ok := stmt.(*ast.Const); ok {
+			continue // Emitted in Module.Constants
+		}
+		if comp, ok := stmt.(*ast.Component); ok && comp.IsGeneric() {
+			continue // Only its instances are registered
+		}
+		if !first {
+			g.writeLine("") // Add blank line between statements
+		}
+		first = false
+
 		switch n := stmt.(type) {
 		case *ast.Component:
 			if err := g.generateComponent(n); err != nil {
 				return "", err
 			}
 		case *ast.System:
-			// Add the wrapper here
-			g.writeLine("world:system({")
+			g.writeLine(fmt.Sprintf("Module.Systems.%s = {", n.Name))
 			g.indent++
 			if err := g.generateSystem(n); err != nil {
 				return "", err
 			}
 			g.indent--
-			g.writeLine("})")
+			g.writeLine("}")
 		case *ast.Relationship:
 			if err := g.generateRelationship(n); err != nil {
 				return "", err
 			}
+		case *ast.Event:
+			g.generateEvent(n, eventReaders(program, n.Name))
+		case *ast.Resource:
+			g.generateResource(n)
+		case *ast.Prefab:
+			if err := g.generatePrefab(flattenPrefab(n, program)); err != nil {
+				return "", err
+			}
+		case *ast.Observer:
+			if err := g.generateObserver(n); err != nil {
+				return "", err
+			}
 		default:
 			return "", fmt.Errorf("unknown statement node type in Generate: %T", n)
 		}
+	}
 
-		if i < len(program.Statements)-1 {
-			g.writeLine("") // Add blank line between statements
-		}
+	if comps := registeredComponents(program); len(comps) > 0 {
+		g.writeLine("")
+		g.writeComponentIds(comps)
+	}
+	if hasSchema(program) {
+		g.writeLine("")
+		g.writeSchema(program)
+	}
+	if len(replicated) > 0 {
+		g.writeLine("")
+		g.generateReplication(replicated)
+	}
+	if len(batches) > 0 {
+		g.writeLine("")
+		g.generateScheduler(batches)
 	}
 
 	// Write footer
@@ -105,6 +225,10 @@ func (g *Generator) writeLine(line string) {
 // --- Expression Generation ---
 
 func (g *Generator) generateExpression(expr ast.Expression) (string, error) {
+	if folded, ok, err := g.foldExpression(expr); err != nil || ok {
+		return folded, err
+	}
+
 	switch e := expr.(type) {
 	case *ast.Identifier:
 		return g.generateIdentifier(e)
@@ -114,6 +238,8 @@ func (g *Generator) generateExpression(expr ast.Expression) (string, error) {
 		return g.generateStringLiteral(e)
 	case *ast.BooleanLiteral:
 		return g.generateBooleanLiteral(e)
+	case *ast.NilLiteral:
+		return "nil", nil
 	case *ast.TableConstructor:
 		return g.generateTableConstructor(e)
 	case *ast.CallExpression:
@@ -122,13 +248,21 @@ func (g *Generator) generateExpression(expr ast.Expression) (string, error) {
 		return g.generatePrefixExpression(e)
 	case *ast.MemberAccessExpression:
 		return g.generateMemberAccessExpression(e)
-	// TODO: Add cases for other expression types (infix, prefix, member access, etc.)
+	case *ast.InfixExpression:
+		return g.generateInfixExpression(e)
+	case *ast.IndexExpression:
+		return g.generateIndexExpression(e)
+	case *ast.MethodCallExpression:
+		return g.generateMethodCallExpression(e)
 	default:
 		return "", fmt.Errorf("unknown expression type in generator: %T", e)
 	}
 }
 
 func (g *Generator) generateIdentifier(ident *ast.Identifier) (string, error) {
+	if arg, ok := g.bindings[ident.Value]; ok {
+		return arg, nil
+	}
 	// For now, just return the identifier name. Might need mapping later.
 	return ident.Value, nil
 }
@@ -226,14 +360,87 @@ func (g *Generator) generateCallExpression(call *ast.CallExpression) (string, er
 	return fmt.Sprintf("%s(%s)", funcStr, strings.Join(argStrs, ", ")), nil
 }
 
+// luauOperators maps EJECS operators to their Luau spelling
+var luauOperators = map[string]string{
+	"&&": "and",
+	"||": "or",
+	"!=": "~=",
+	"!":  "not",
+}
+
+// luauPrecedence is the binding strength of Luau binary operators
+var luauPrecedence = map[string]int{
+	"or":  1,
+	"and": 2,
+	"==":  3,
+	"~=":  3,
+	"<":   3,
+	">":   3,
+	"<=":  3,
+	">=":  3,
+	"..":  4,
+	"+":   5,
+	"-":   5,
+	"*":   6,
+	"/":   6,
+}
+
+// luauOperator returns the Luau spelling of an EJECS operator
+func luauOperator(op string) string {
+	if luauOp, ok := luauOperators[op]; ok {
+		return luauOp
+	}
+	return op
+}
+
 // Generate code for PrefixExpression
 func (g *Generator) generatePrefixExpression(pe *ast.PrefixExpression) (string, error) {
 	rightStr, err := g.generateExpression(pe.Right)
 	if err != nil {
 		return "", err
 	}
+	if _, ok := pe.Right.(*ast.InfixExpression); ok {
+		rightStr = "(" + rightStr + ")"
+	}
+	op := luauOperator(pe.Operator)
+	if op == "not" || strings.HasPrefix(rightStr, "-") {
+		// "not x", and "- -x" since "--" starts a comment
+		return op + " " + rightStr, nil
+	}
 	// Combine operator and operand (e.g., "-10")
-	return pe.Operator + rightStr, nil
+	return op + rightStr, nil
+}
+
+// Generate code for InfixExpression, parenthesizing operands only where
+// Luau's precedence would otherwise regroup them
+func (g *Generator) generateInfixExpression(ie *ast.InfixExpression) (string, error) {
+	op := luauOperator(ie.Operator)
+	prec := luauPrecedence[op]
+	rightAssoc := op == ".."
+
+	operand := func(expr ast.Expression, isRight bool) (string, error) {
+		str, err := g.generateExpression(expr)
+		if err != nil {
+			return "", err
+		}
+		if child, ok := expr.(*ast.InfixExpression); ok {
+			childPrec := luauPrecedence[luauOperator(child.Operator)]
+			if childPrec < prec || (childPrec == prec && isRight != rightAssoc) {
+				str = "(" + str + ")"
+			}
+		}
+		return str, nil
+	}
+
+	left, err := operand(ie.Left, false)
+	if err != nil {
+		return "", err
+	}
+	right, err := operand(ie.Right, true)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", left, op, right), nil
 }
 
 // Generate code for MemberAccessExpression
@@ -246,6 +453,51 @@ func (g *Generator) generateMemberAccessExpression(ma *ast.MemberAccessExpressio
 	return fmt.Sprintf("%s.%s", leftStr, ma.MemberName.Value), nil
 }
 
+// generatePrefixOperand generates the expression an index or method call
+// applies to, parenthesizing it where Luau requires it (e.g. ("a"):upper())
+func (g *Generator) generatePrefixOperand(expr ast.Expression) (string, error) {
+	str, err := g.generateExpression(expr)
+	if err != nil {
+		return "", err
+	}
+	switch expr.(type) {
+	case *ast.Identifier, *ast.MemberAccessExpression, *ast.CallExpression, *ast.IndexExpression, *ast.MethodCallExpression:
+		// A folded constant is a literal and needs parentheses too
+		if _, folded, _ := g.foldExpression(expr); !folded {
+			return str, nil
+		}
+	}
+	return "(" + str + ")", nil
+}
+
+// Generate code for IndexExpression
+func (g *Generator) generateIndexExpression(ie *ast.IndexExpression) (string, error) {
+	left, err := g.generatePrefixOperand(ie.Left)
+	if err != nil {
+		return "", err
+	}
+	index, err := g.generateExpression(ie.Index)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s[%s]", left, index), nil
+}
+
+// Generate code for MethodCallExpression
+func (g *Generator) generateMethodCallExpression(mc *ast.MethodCallExpression) (string, error) {
+	object, err := g.generatePrefixOperand(mc.Object)
+	if err != nil {
+		return "", err
+	}
+	args := make([]string, len(mc.Arguments))
+	for i, arg := range mc.Arguments {
+		if args[i], err = g.generateExpression(arg); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("%s:%s(%s)", object, mc.Method.Value, strings.Join(args, ", ")), nil
+}
+
 // Update getDefaultValue to call generateExpression
 func (g *Generator) getDefaultValue(defaultValue ast.Expression, fieldType string, isOptional bool) string {
 	if defaultValue != nil {
@@ -270,21 +522,19 @@ func (g *Generator) getDefaultValue(defaultValue ast.Expression, fieldType strin
 		return "\"\""
 	case "boolean":
 		return "false"
-	case "Vector2":
-		return "Vector2.new(0, 0)"
-	case "Vector3":
-		return "Vector3.new(0, 0, 0)"
-	case "CFrame":
-		return "CFrame.new()"
-	case "Color3":
-		return "Color3.new(1, 1, 1)"
-	case "UDim2":
-		return "UDim2.new(0, 0, 0, 0)"
-	case "UDim":
-		return "UDim.new(0, 0)"
 	case "table":
 		return "{}"
 	default:
+		// Typed enums default to their first item
+		if enum := enumName(fieldType); enum != "" {
+			if e := robloxapi.Current().Enum(enum); e != nil && len(e.Items) > 0 {
+				return fmt.Sprintf("Enum.%s.%s", enum, e.Items[0].Name)
+			}
+		}
+		// Roblox datatypes take their default from the API database
+		if dt := robloxapi.Current().DataType(fieldType); dt != nil && dt.Default != "" {
+			return dt.Default
+		}
 		return "nil"
 	}
 }
@@ -299,6 +549,20 @@ func (g *Generator) generateComponent(comp *ast.Component) error {
 		g.writeLine(fmt.Sprintf("-- Component Attribute: @%s", attr))
 	}
 
+	replicated := hasAttribute(comp, "replicated")
+	for _, field := range comp.Fields {
+		reserved := componentMembers[field.Name] ||
+			(replicated && (containsString(replicatedMembers, field.Name) || containsString(deltaMembers, field.Name))) ||
+			(comp.Version > 0 && containsString(versionedMembers, field.Name)) ||
+			(hasAttribute(comp, "persistent") && containsString(persistentMembers, field.Name))
+		if reserved {
+			return fmt.Errorf("component %s: field name '%s' collides with a generated function", comp.Name, field.Name)
+		}
+	}
+
+	g.generateComponentType(comp)
+	g.writeLine("")
+
 	// Use Module.Components. prefix again
 	g.writeLine(fmt.Sprintf("Module.Components.%s = {", comp.Name))
 	g.indent++
@@ -327,12 +591,40 @@ func (g *Generator) generateComponent(comp *ast.Component) error {
 	}
 	g.indent--
 	g.writeLine("}")
+	g.writeLine("")
+	g.generateConstructor(comp)
+	g.writeLine("")
+	if err := g.generateValidator(comp); err != nil {
+		return err
+	}
+	if comp.Version > 0 {
+		g.writeLine("")
+		if err := g.generateMigrate(comp); err != nil {
+			return err
+		}
+	}
+	if hasAttribute(comp, "persistent") {
+		g.writeLine("")
+		if err := g.generatePersistence(comp); err != nil {
+			return err
+		}
+	}
+	if replicated {
+		g.writeLine("")
+		if err := g.generateSerializer(comp); err != nil {
+			return err
+		}
+		g.writeLine("")
+		if err := g.generateDelta(comp); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (g *Generator) generateSystem(system *ast.System) error {
 	// This function now generates ONLY the *content* of the system table
-	// The Generate function will add the world:system wrapper
+	// The Generate function will add the Module.Systems.<Name> wrapper
 
 	// System Name
 	g.writeLine(fmt.Sprintf("name = %q,", system.Name))
@@ -400,13 +692,13 @@ func (g *Generator) generateSystem(system *ast.System) error {
 		g.writeLine("},") // Comma after 'query' block
 	}
 
-	// Frequency
-	if system.Frequency != nil {
-		freqStr, err := g.generateExpression(system.Frequency)
+	// Frequency, which a phase sets to its RunService event
+	if system.Frequency != nil || system.Phase != "" {
+		sched, err := g.resolveFrequency(system)
 		if err != nil {
-			freqStr = fmt.Sprintf("nil --[[ ERROR: %v ]]", err)
+			return err
 		}
-		g.writeLine(fmt.Sprintf("frequency = %s,", freqStr))
+		g.writeLine(fmt.Sprintf("frequency = %s,", sched.Luau()))
 	}
 
 	// Priority
@@ -418,28 +710,25 @@ func (g *Generator) generateSystem(system *ast.System) error {
 		g.writeLine(fmt.Sprintf("priority = %s,", prioStr))
 	}
 
-	// Callback
-	if system.Code != "" {
-		var args []string
-		args = append(args, "entity", "components") // Base arguments
-		if len(system.Parameters) > 0 {
-			for _, param := range system.Parameters {
-				args = append(args, param.Name)
-			}
-		}
-
-		g.writeLine("callback = function(" + strings.Join(args, ", ") + ")")
-		g.indent++ // Indent for the code block content
+	// Phase
+	if system.Phase != "" {
+		g.writeLine(fmt.Sprintf("phase = %q,", system.Phase))
+	}
 
-		// Process code block content line by line
-		codeLines := strings.Split(strings.TrimSpace(system.Code), "\n")
-		for _, line := range codeLines {
-			trimmedLine := strings.TrimRight(line, " \t")
-			g.writeLine(trimmedLine)
+	// Resources, passed to the callback after the parameters
+	if len(system.Uses) > 0 {
+		quoted := make([]string, len(system.Uses))
+		for i, name := range system.Uses {
+			quoted[i] = fmt.Sprintf("%q", name)
 		}
+		g.writeLine(fmt.Sprintf("uses = { %s },", strings.Join(quoted, ", ")))
+	}
 
-		g.indent--          // Dedent after the code block
-		g.writeLine("end,") // Add comma after callback function
+	// Callback and the loop that runs it
+	if system.Code != "" {
+		if err := g.generateCallback(system); err != nil {
+			return err
+		}
 	}
 
 	// Remove trailing comma cleanly
@@ -483,15 +772,55 @@ func (g *Generator) generateRelationship(rel *ast.Relationship) error {
 
 // Remove generateSystemWithIndent and other unused helpers if they exist
 
+func containsString(list []string, s string) bool {
+	return indexOf(list, s) >= 0
+}
+
+// indexOf returns the position of s in list, or -1
+func indexOf(list []string, s string) int {
+	for i, item := range list {
+		if item == s {
+			return i
+		}
+	}
+	return -1
+}
+
 func luauType(t string) string {
 	switch t {
-	case "number":
+	case "number", "int", "float":
 		return "number"
 	case "string":
 		return "string"
 	case "boolean":
 		return "boolean"
+	case "Entity":
+		return "number" // Entity ids are numbers in the ECS runtimes
 	default:
+		if checker.IsDataType(t) || enumName(t) != "" {
+			return t
+		}
 		return "any"
 	}
 }
+
+// enumName returns "Material" for the typed enum "Enum.Material", or ""
+func enumName(t string) string {
+	name, _ := strings.CutPrefix(t, "Enum.")
+	if name == t {
+		return ""
+	}
+	return name
+}
+
+// fieldLuauType returns the Luau type annotation for a component field
+func fieldLuauType(field *ast.Field) string {
+	t := luauType(field.Type)
+	if field.Type == "table" {
+		t = fmt.Sprintf("{ [%s]: %s }", luauType(field.MapKeyType), luauType(field.MapValueType))
+	}
+	if field.Optional && t != "any" {
+		t += "?"
+	}
+	return t
+}
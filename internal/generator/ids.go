@@ -0,0 +1,57 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// registeredComponents returns the components of program that are registered
+// in Module.Components: every component but generic templates
+func registeredComponents(program *ast.Program) []*ast.Component {
+	var comps []*ast.Component
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok && !comp.IsGeneric() {
+			comps = append(comps, comp)
+		}
+	}
+	return comps
+}
+
+// componentID returns the expression of the id the selected library knows a
+// component by
+func componentID(name string) string {
+	return "Module.Ids." + name
+}
+
+// writeComponentIds emits Module.Ids and Module.init, which creates the id of
+// every component with the selected library. JECS ids belong to a world, so
+// init takes the world; ECR ids are global and init takes the ecr module.
+// Queries, prefabs and observers use these ids, so init must run first.
+func (g *Generator) writeComponentIds(comps []*ast.Component) {
+	g.writeLine("-- Component ids of the ECS library, created by Module.init")
+	g.writeLine("Module.Ids = {} :: { [string]: any }")
+	g.writeLine("")
+	if g.library == LibraryECR {
+		g.writeLine("-- Creates the ECR id of every component. Call it once, before running")
+		g.writeLine("-- systems, spawning prefabs or connecting observers.")
+		g.writeLine("function Module.init(ecr: any)")
+	} else {
+		g.writeLine("-- Creates the JECS id of every component in world. Call it once, before")
+		g.writeLine("-- running systems, spawning prefabs or connecting observers.")
+		g.writeLine("function Module.init(world: any)")
+	}
+	g.indent++
+	g.writeLine("if next(Module.Ids) ~= nil then")
+	g.writeLine(`    error("Module.init: component ids are already created", 2)`)
+	g.writeLine("end")
+	create := "world:component()"
+	if g.library == LibraryECR {
+		create = "ecr.component()"
+	}
+	for _, comp := range comps {
+		g.writeLine(fmt.Sprintf("%s = %s", componentID(comp.Name), create))
+	}
+	g.indent--
+	g.writeLine("end")
+}
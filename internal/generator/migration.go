@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// versionedMembers are the members added to components declared with @version
+var versionedMembers = []string{"Version", "migrate"}
+
+// generateMigrate emits Module.Components.<Name>.Version and migrate(data,
+// fromVersion), which applies every migrate block from fromVersion onwards in
+// order and returns an upgraded copy of data.
+func (g *Generator) generateMigrate(comp *ast.Component) error {
+	path := "Module.Components." + comp.Name
+	g.writeLine(fmt.Sprintf("%s.Version = %d", path, comp.Version))
+	g.writeLine("")
+
+	migrations := make([]*ast.Migration, len(comp.Migrations))
+	copy(migrations, comp.Migrations)
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].From < migrations[j].From })
+
+	g.writeLine(fmt.Sprintf("function %s.migrate(data: { [string]: any }, fromVersion: number): { [string]: any }", path))
+	g.indent++
+	g.writeLine(fmt.Sprintf("if fromVersion > %d then", comp.Version))
+	g.indent++
+	g.writeLine(fmt.Sprintf(`error(string.format("%s.migrate: data version %%d is newer than schema version %d", fromVersion), 2)`, comp.Name, comp.Version))
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("local migrated = table.clone(data)")
+	for _, m := range migrations {
+		g.writeLine(fmt.Sprintf("if fromVersion <= %d then", m.From))
+		g.indent++
+		for _, step := range m.Steps {
+			target := "migrated." + step.Field
+			switch step.Action {
+			case ast.MigrateRename:
+				g.writeLine(fmt.Sprintf("migrated.%s = %s", step.To, target))
+				g.writeLine(target + " = nil")
+			case ast.MigrateDefault:
+				value, err := g.generateExpression(step.Value)
+				if err != nil {
+					return fmt.Errorf("component %s: migrate from %d: default %s: %v", comp.Name, m.From, step.Field, err)
+				}
+				g.writeLine(fmt.Sprintf("if %s == nil then", target))
+				g.indent++
+				g.writeLine(fmt.Sprintf("%s = %s", target, value))
+				g.indent--
+				g.writeLine("end")
+			case ast.MigrateRemove:
+				g.writeLine(target + " = nil")
+			}
+		}
+		g.indent--
+		g.writeLine("end")
+	}
+	g.writeLine("return migrated")
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
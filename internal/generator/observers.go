@@ -0,0 +1,83 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// observerHooks maps observer triggers to the JECS hook and the ECR signal
+// that report them
+var observerHooks = map[string]struct{ jecs, ecr string }{
+	"added":   {"OnAdd", "on_add"},
+	"changed": {"OnSet", "on_change"},
+	"removed": {"OnRemove", "on_remove"},
+}
+
+// observers returns the observer declarations of program
+func observers(program *ast.Program) []*ast.Observer {
+	var out []*ast.Observer
+	for _, stmt := range program.Statements {
+		if observer, ok := stmt.(*ast.Observer); ok {
+			out = append(out, observer)
+		}
+	}
+	return out
+}
+
+// generateObserver emits Module.Observers.<Name>: the callback, which takes
+// the entity and the observed component, and connect(world), which registers
+// it with the selected library. With JECS connect also takes the jecs module,
+// whose OnAdd, OnSet and OnRemove hooks are set on the component, chaining to
+// the hook already set so several observers can watch the same change.
+func (g *Generator) generateObserver(observer *ast.Observer) error {
+	path := "Module.Observers." + observer.Name
+	component := componentID(observer.Component)
+	arg := argName(observer.Component)
+	if arg == "entity" {
+		return fmt.Errorf("observer %s: component %s and the entity are both passed to the callback as 'entity'", observer.Name, observer.Component)
+	}
+
+	g.writeLine(path + " = {")
+	g.indent++
+	g.writeLine(fmt.Sprintf("name = %q,", observer.Name))
+	g.writeLine(fmt.Sprintf("on = %q,", observer.Trigger))
+	g.writeLine(fmt.Sprintf("component = %q,", observer.Component))
+	g.writeCode(fmt.Sprintf("callback = function(entity: Entity, %s: %s)", arg, observer.Component), observer.Code)
+	g.writeLine("end,")
+
+	hook := observerHooks[observer.Trigger]
+	if g.library == LibraryECR {
+		g.writeLine("connect = function(world: any)")
+		g.indent++
+		g.writeLine(fmt.Sprintf("local callback = %s.callback", path))
+		g.writeLine(fmt.Sprintf("world:%s(%s):connect(function(entity: Entity)", hook.ecr, component))
+	} else {
+		g.writeLine("connect = function(world: any, jecs: any)")
+		g.indent++
+		g.writeLine(fmt.Sprintf("local callback = %s.callback", path))
+		// A component has one hook per kind, so the hook calls the one it replaces
+		g.writeLine(fmt.Sprintf("local previous = world:get(%s, jecs.%s)", component, hook.jecs))
+		g.writeLine(fmt.Sprintf("world:set(%s, jecs.%s, function(entity: Entity, ...)", component, hook.jecs))
+		g.indent++
+		g.writeLine("if previous ~= nil then")
+		g.writeLine("    previous(entity, ...)")
+		g.writeLine("end")
+		g.indent--
+	}
+	g.indent++
+	g.writeLine(fmt.Sprintf("local %s = world:get(entity, %s)", arg, component))
+	if observer.Where != nil {
+		if err := g.writeGuard(observer.Where, []string{observer.Component}, "return"); err != nil {
+			return fmt.Errorf("observer %s: where: %v", observer.Name, err)
+		}
+	}
+	g.writeLine(fmt.Sprintf("callback(entity, %s)", arg))
+	g.indent--
+	g.writeLine("end)")
+	g.indent--
+	g.writeLine("end")
+	g.indent--
+	g.writeLine("}")
+	return nil
+}
@@ -0,0 +1,304 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// persistentMembers are the functions added to @persistent components
+var persistentMembers = []string{"toSaveData", "fromSaveData"}
+
+// saveCodec converts a Roblox type that DataStores cannot store to and from a
+// plain array of numbers or strings
+type saveCodec struct {
+	encode string // Luau expression over v
+	decode string // Luau expression over d
+}
+
+// saveCodecs covers the complex types with a fixed number of components.
+// ColorSequence and NumberSequence are saved as arrays of keypoints, and
+// Instance is never saved. Codecs are emitted in saveCodecOrder.
+var saveCodecs = map[string]saveCodec{
+	"Vector2":            {"{ v.X, v.Y }", "Vector2.new(d[1], d[2])"},
+	"Vector3":            {"{ v.X, v.Y, v.Z }", "Vector3.new(d[1], d[2], d[3])"},
+	"CFrame":             {"{ v:GetComponents() }", "CFrame.new(table.unpack(d))"},
+	"Color3":             {"{ v.R, v.G, v.B }", "Color3.new(d[1], d[2], d[3])"},
+	"NumberRange":        {"{ v.Min, v.Max }", "NumberRange.new(d[1], d[2])"},
+	"UDim":               {"{ v.Scale, v.Offset }", "UDim.new(d[1], d[2])"},
+	"UDim2":              {"{ v.X.Scale, v.X.Offset, v.Y.Scale, v.Y.Offset }", "UDim2.new(d[1], d[2], d[3], d[4])"},
+	"Ray":                {"{ v.Origin.X, v.Origin.Y, v.Origin.Z, v.Direction.X, v.Direction.Y, v.Direction.Z }", "Ray.new(Vector3.new(d[1], d[2], d[3]), Vector3.new(d[4], d[5], d[6]))"},
+	"Region3":            {"{ v.CFrame.X - v.Size.X / 2, v.CFrame.Y - v.Size.Y / 2, v.CFrame.Z - v.Size.Z / 2, v.CFrame.X + v.Size.X / 2, v.CFrame.Y + v.Size.Y / 2, v.CFrame.Z + v.Size.Z / 2 }", "Region3.new(Vector3.new(d[1], d[2], d[3]), Vector3.new(d[4], d[5], d[6]))"},
+	"Region3int16":       {"{ v.Min.X, v.Min.Y, v.Min.Z, v.Max.X, v.Max.Y, v.Max.Z }", "Region3int16.new(Vector3int16.new(d[1], d[2], d[3]), Vector3int16.new(d[4], d[5], d[6]))"},
+	"Rect":               {"{ v.Min.X, v.Min.Y, v.Max.X, v.Max.Y }", "Rect.new(d[1], d[2], d[3], d[4])"},
+	"EnumItem":           {"{ tostring(v.EnumType), v.Name }", "(Enum :: any)[d[1]][d[2]]"},
+	"BrickColor":         {"{ v.Number }", "BrickColor.new(d[1])"},
+	"Vector2int16":       {"{ v.X, v.Y }", "Vector2int16.new(d[1], d[2])"},
+	"Vector3int16":       {"{ v.X, v.Y, v.Z }", "Vector3int16.new(d[1], d[2], d[3])"},
+	"Font":               {"{ v.Family, v.Weight.Name, v.Style.Name }", "Font.new(d[1], (Enum.FontWeight :: any)[d[2]], (Enum.FontStyle :: any)[d[3]])"},
+	"PhysicalProperties": {"{ v.Density, v.Friction, v.Elasticity, v.FrictionWeight, v.ElasticityWeight }", "PhysicalProperties.new(d[1], d[2], d[3], d[4], d[5])"},
+	"DateTime":           {"{ v.UnixTimestampMillis }", "DateTime.fromUnixTimestampMillis(d[1])"},
+}
+
+var saveCodecOrder = []string{
+	"Vector2", "Vector3", "CFrame", "Color3", "ColorSequence", "NumberRange", "NumberSequence",
+	"UDim", "UDim2", "Ray", "Region3", "Region3int16", "Rect", "EnumItem", "BrickColor",
+	"Vector2int16", "Vector3int16", "Font", "PhysicalProperties", "DateTime",
+}
+
+// isSaved reports whether a field of a @persistent component is written to
+// save data. @transient and Instance fields keep their defaults on load.
+func isSaved(field *ast.Field) bool {
+	return field.Attribute("transient") == nil && field.Type != "Instance" &&
+		!(field.Type == "table" && field.MapValueType == "Instance")
+}
+
+// codecType returns the complex type whose codec a saved field needs, or ""
+func codecType(field *ast.Field) string {
+	t := field.Type
+	if t == "table" {
+		t = field.MapValueType
+	}
+	if enumName(t) != "" {
+		t = "EnumItem" // Typed enums are saved like any other EnumItem
+	}
+	if !containsString(saveCodecOrder, t) {
+		return ""
+	}
+	return t
+}
+
+// saveKey returns the Luau expressions that turn a map key of a saved field
+// into a DataStore key and back, over key. DataStores only store string keys.
+func saveKey(comp *ast.Component, field *ast.Field) (encode, decode string, err error) {
+	switch t := field.MapKeyType; {
+	case t == "" || t == "string":
+		return "key", "key", nil
+	case t == "number" || t == "int" || t == "float":
+		return "tostring(key)", "tonumber(key)", nil
+	case t == "boolean":
+		return "tostring(key)", `key == "true"`, nil
+	case enumName(t) != "":
+		return "key.Name", fmt.Sprintf("(%s :: any)[key]", t), nil
+	default:
+		return "", "", fmt.Errorf("component %s: field '%s': @persistent maps can't save %s keys, use string, number, boolean or a typed enum", comp.Name, field.Name, t)
+	}
+}
+
+// clearsToNil reports whether a saved field needs an explicit marker when it
+// is nil: its constructor default isn't nil, so fromSaveData would restore the
+// default instead
+func clearsToNil(field *ast.Field) bool {
+	if !field.Optional || field.DefaultValue == nil {
+		return false
+	}
+	_, isNil := field.DefaultValue.(*ast.NilLiteral)
+	return !isNil
+}
+
+// writeSaveCodecs emits the SaveCodecs local with the codecs used by the
+// given @persistent components
+func (g *Generator) writeSaveCodecs(comps []*ast.Component) {
+	used := map[string]bool{}
+	for _, comp := range comps {
+		for _, field := range comp.Fields {
+			if isSaved(field) && codecType(field) != "" {
+				used[codecType(field)] = true
+			}
+		}
+	}
+
+	if len(used) == 0 {
+		return
+	}
+	g.writeLine("local SaveCodecs = {")
+	g.indent++
+	for _, name := range saveCodecOrder {
+		if !used[name] {
+			continue
+		}
+		g.writeLine(name + " = {")
+		g.indent++
+		g.writeSaveCodecFunctions(name)
+		g.indent--
+		g.writeLine("},")
+	}
+	g.indent--
+	g.writeLine("}")
+	g.writeLine("")
+}
+
+func (g *Generator) writeSaveCodecFunctions(name string) {
+	switch name {
+	case "ColorSequence", "NumberSequence":
+		// Keypoints are saved as nested arrays
+		keypoint := "{ k.Time, k.Value.R, k.Value.G, k.Value.B }"
+		restore := "ColorSequenceKeypoint.new(k[1], Color3.new(k[2], k[3], k[4]))"
+		if name == "NumberSequence" {
+			keypoint = "{ k.Time, k.Value, k.Envelope }"
+			restore = "NumberSequenceKeypoint.new(k[1], k[2], k[3])"
+		}
+		g.writeLine("encode = function(v)")
+		g.indent++
+		g.writeLine("local keypoints = {}")
+		g.writeLine("for _, k in v.Keypoints do")
+		g.indent++
+		g.writeLine("table.insert(keypoints, " + keypoint + ")")
+		g.indent--
+		g.writeLine("end")
+		g.writeLine("return keypoints")
+		g.indent--
+		g.writeLine("end,")
+		g.writeLine("decode = function(d)")
+		g.indent++
+		g.writeLine("local keypoints = {}")
+		g.writeLine("for _, k in d do")
+		g.indent++
+		g.writeLine("table.insert(keypoints, " + restore + ")")
+		g.indent--
+		g.writeLine("end")
+		g.writeLine(fmt.Sprintf("return %s.new(keypoints)", name))
+		g.indent--
+		g.writeLine("end,")
+	default:
+		codec := saveCodecs[name]
+		g.writeLine(fmt.Sprintf("encode = function(v) return %s end,", codec.encode))
+		g.writeLine(fmt.Sprintf("decode = function(d) return %s end,", codec.decode))
+	}
+}
+
+// generatePersistence emits toSaveData/fromSaveData for a @persistent
+// component. Versioned components stamp _version into the save data and run
+// migrate before loading. Optional fields with a default are listed in _nil
+// when they are nil, so loading doesn't bring the default back.
+func (g *Generator) generatePersistence(comp *ast.Component) error {
+	path := "Module.Components." + comp.Name
+	for _, field := range comp.Fields {
+		if isSaved(field) && field.Type == "table" {
+			if _, _, err := saveKey(comp, field); err != nil {
+				return err
+			}
+		}
+	}
+
+	g.writeLine(fmt.Sprintf("function %s.toSaveData(component: %s): { [string]: any }", path, comp.Name))
+	g.indent++
+	g.writeLine("local data = {}")
+	if comp.Version > 0 {
+		g.writeLine(fmt.Sprintf("data._version = %d", comp.Version))
+	}
+	for _, field := range comp.Fields {
+		if !isSaved(field) {
+			continue
+		}
+		source := "component." + field.Name
+		target := "data." + field.Name
+		codec := codecType(field)
+		keyEncode, _, _ := saveKey(comp, field)
+		// markNil fields write their value in the else branch of the nil check,
+		// where source isn't nil
+		markNil := clearsToNil(field)
+		if markNil {
+			g.writeLine(fmt.Sprintf("if %s == nil then", source))
+			g.indent++
+			g.writeLine("data._nil = data._nil or {}")
+			g.writeLine(fmt.Sprintf("table.insert(data._nil, %q)", field.Name))
+			g.indent--
+			g.writeLine("else")
+			g.indent++
+		}
+		switch {
+		case field.Type == "table" && (codec != "" || keyEncode != "key"):
+			item := "deepCopy(item)"
+			if codec != "" {
+				item = fmt.Sprintf("SaveCodecs.%s.encode(item)", codec)
+			}
+			if !markNil {
+				g.writeLine(fmt.Sprintf("if %s ~= nil then", source))
+				g.indent++
+			}
+			g.writeLine(target + " = {}")
+			g.writeLine(fmt.Sprintf("for key, item in pairs(%s) do", source))
+			g.indent++
+			g.writeLine(fmt.Sprintf("%s[%s] = %s", target, keyEncode, item))
+			g.indent--
+			g.writeLine("end")
+			if !markNil {
+				g.indent--
+				g.writeLine("end")
+			}
+		case codec != "":
+			if field.Optional && !markNil {
+				g.writeLine(fmt.Sprintf("if %s ~= nil then", source))
+				g.indent++
+			}
+			g.writeLine(fmt.Sprintf("%s = SaveCodecs.%s.encode(%s)", target, codec, source))
+			if field.Optional && !markNil {
+				g.indent--
+				g.writeLine("end")
+			}
+		case isTableField(field):
+			g.writeLine(fmt.Sprintf("%s = deepCopy(%s)", target, source))
+		default:
+			g.writeLine(fmt.Sprintf("%s = %s", target, source))
+		}
+		if markNil {
+			g.indent--
+			g.writeLine("end")
+		}
+	}
+	g.writeLine("return data")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine("")
+
+	g.writeLine(fmt.Sprintf("function %s.fromSaveData(data: { [string]: any }): %s", path, comp.Name))
+	g.indent++
+	if comp.Version > 0 {
+		g.writeLine(fmt.Sprintf("data = %s.migrate(data, data._version or 1)", path))
+	}
+	// Fields missing from the save data keep their defaults
+	g.writeLine(fmt.Sprintf("local component = %s.new()", path))
+	for _, field := range comp.Fields {
+		if !isSaved(field) {
+			continue
+		}
+		source := "data." + field.Name
+		target := "component." + field.Name
+		g.writeLine(fmt.Sprintf("if %s ~= nil then", source))
+		g.indent++
+		codec := codecType(field)
+		_, keyDecode, _ := saveKey(comp, field)
+		switch {
+		case field.Type == "table" && (codec != "" || keyDecode != "key"):
+			item := "deepCopy(item)"
+			if codec != "" {
+				item = fmt.Sprintf("SaveCodecs.%s.decode(item)", codec)
+			}
+			g.writeLine(target + " = {}")
+			g.writeLine(fmt.Sprintf("for key, item in pairs(%s) do", source))
+			g.indent++
+			g.writeLine(fmt.Sprintf("%s[%s] = %s", target, keyDecode, item))
+			g.indent--
+			g.writeLine("end")
+		case codec != "":
+			g.writeLine(fmt.Sprintf("%s = SaveCodecs.%s.decode(%s)", target, codec, source))
+		case isTableField(field):
+			g.writeLine(fmt.Sprintf("%s = deepCopy(%s)", target, source))
+		default:
+			g.writeLine(fmt.Sprintf("%s = %s", target, source))
+		}
+		g.indent--
+		if clearsToNil(field) {
+			g.writeLine(fmt.Sprintf("elseif data._nil ~= nil and table.find(data._nil, %q) then", field.Name))
+			g.indent++
+			g.writeLine(target + " = nil")
+			g.indent--
+		}
+		g.writeLine("end")
+	}
+	g.writeLine("return component")
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
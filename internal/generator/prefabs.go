@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// prefabs returns the prefab declarations of program
+func prefabs(program *ast.Program) []*ast.Prefab {
+	var out []*ast.Prefab
+	for _, stmt := range program.Statements {
+		if prefab, ok := stmt.(*ast.Prefab); ok {
+			out = append(out, prefab)
+		}
+	}
+	return out
+}
+
+// flattenPrefab returns prefab with the components of the prefabs it extends.
+// Base components come first, in their order. A component the prefab repeats
+// keeps its place and takes the prefab's overrides over the base's, field by
+// field. The prefabs must have passed the checker.
+func flattenPrefab(prefab *ast.Prefab, program *ast.Program) *ast.Prefab {
+	if prefab.Extends == "" {
+		return prefab
+	}
+	var base *ast.Prefab
+	for _, p := range prefabs(program) {
+		if p.Name == prefab.Extends {
+			base = flattenPrefab(p, program)
+		}
+	}
+
+	flat := &ast.Prefab{Name: prefab.Name, Extends: prefab.Extends, Line: prefab.Line, Column: prefab.Column}
+	for _, comp := range base.Components {
+		overrides := append([]*ast.PrefabOverride(nil), comp.Overrides...)
+		flat.Components = append(flat.Components, &ast.PrefabComponent{Name: comp.Name, Overrides: overrides})
+	}
+	for _, comp := range prefab.Components {
+		var inherited *ast.PrefabComponent
+		for _, c := range flat.Components {
+			if c.Name == comp.Name {
+				inherited = c
+			}
+		}
+		if inherited == nil {
+			flat.Components = append(flat.Components, comp)
+			continue
+		}
+		for _, override := range comp.Overrides {
+			replaced := false
+			for i, o := range inherited.Overrides {
+				if o.Field == override.Field {
+					inherited.Overrides[i] = override
+					replaced = true
+				}
+			}
+			if !replaced {
+				inherited.Overrides = append(inherited.Overrides, override)
+			}
+		}
+	}
+	return flat
+}
+
+// generatePrefab emits Module.Prefabs.<Name>, listing its components, and its
+// spawn function. Each component is built with its constructor, so fields the
+// prefab doesn't set keep their defaults. prefab must be flattened.
+func (g *Generator) generatePrefab(prefab *ast.Prefab) error {
+	path := "Module.Prefabs." + prefab.Name
+	quoted := make([]string, len(prefab.Components))
+	for i, comp := range prefab.Components {
+		quoted[i] = fmt.Sprintf("%q", comp.Name)
+	}
+	list := "{}"
+	if len(quoted) > 0 {
+		list = "{ " + strings.Join(quoted, ", ") + " }"
+	}
+	if prefab.Extends != "" {
+		g.writeLine(fmt.Sprintf("%s = { components = %s, extends = %q }", path, list, prefab.Extends))
+	} else {
+		g.writeLine(fmt.Sprintf("%s = { components = %s }", path, list))
+	}
+	g.writeLine("")
+
+	g.writeLine(fmt.Sprintf("-- Creates an entity from the %s prefab. overrides maps component names to", prefab.Name))
+	g.writeLine("-- the fields that replace the prefab's values.")
+	g.writeLine(fmt.Sprintf("function %s.spawn(world: any, overrides: { [string]: { [string]: any } }?): any", path))
+	g.indent++
+	g.writeLine("local values = overrides or {}")
+	g.writeLine(fmt.Sprintf("local entity = world:%s()", g.createMethod()))
+	for _, comp := range prefab.Components {
+		fields := "values." + comp.Name
+		if len(comp.Overrides) > 0 {
+			set := make([]string, len(comp.Overrides))
+			for i, override := range comp.Overrides {
+				value, err := g.generateExpression(override.Value)
+				if err != nil {
+					return fmt.Errorf("prefab %s: %s.%s: %v", prefab.Name, comp.Name, override.Field, err)
+				}
+				set[i] = fmt.Sprintf("%s = %s", override.Field, value)
+			}
+			fields = fmt.Sprintf("mergeFields({ %s }, %s)", strings.Join(set, ", "), fields)
+		}
+		g.writeLine(fmt.Sprintf("world:set(entity, %s, Module.Components.%s.new(%s))", componentID(comp.Name), comp.Name, fields))
+	}
+	g.writeLine("return entity")
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
+
+// writePrefabPrelude emits Module.Prefabs and the helper that applies spawn
+// overrides over the values set by a prefab
+func (g *Generator) writePrefabPrelude() {
+	lines := []string{
+		"Module.Prefabs = {}",
+		"",
+		"local function mergeFields(base: { [string]: any }, overrides: { [string]: any }?): { [string]: any }",
+		"    local merged = table.clone(base)",
+		"    for key, value in pairs(overrides or {}) do",
+		"        merged[key] = value",
+		"    end",
+		"    return merged",
+		"end",
+		"",
+	}
+	for _, line := range lines {
+		g.writeLine(line)
+	}
+}
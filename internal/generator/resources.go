@@ -0,0 +1,87 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// resources returns the resource declarations of program
+func resources(program *ast.Program) []*ast.Resource {
+	var out []*ast.Resource
+	for _, stmt := range program.Statements {
+		if resource, ok := stmt.(*ast.Resource); ok {
+			out = append(out, resource)
+		}
+	}
+	return out
+}
+
+// generateResource emits the type, defaults and constructor of a resource
+// plus get/set accessors for its value on a world
+func (g *Generator) generateResource(resource *ast.Resource) {
+	path := "Module.Resources." + resource.Name
+	comp := &ast.Component{Name: resource.Name, Fields: resource.Fields}
+
+	g.generateComponentType(comp)
+	g.writeLine("")
+	g.writeLine(path + " = {")
+	g.indent++
+	for i, field := range resource.Fields {
+		comma := ","
+		if i == len(resource.Fields)-1 {
+			comma = ""
+		}
+		g.writeLine(fmt.Sprintf("%s = %s%s", field.Name, g.getDefaultValue(field.DefaultValue, field.Type, field.Optional), comma))
+	}
+	g.indent--
+	g.writeLine("}")
+	g.writeLine("")
+	g.generateConstructorAt(path, comp)
+	g.writeLine("")
+
+	lines := []string{
+		fmt.Sprintf("-- Returns the %s of world, creating it from the defaults on first use", resource.Name),
+		fmt.Sprintf("function %s.get(world: any): %s", path, resource.Name),
+		fmt.Sprintf("    return getResource(world, %q)", resource.Name),
+		"end",
+		"",
+		fmt.Sprintf("function %s.set(world: any, value: %s)", path, resource.Name),
+		fmt.Sprintf("    resourcesOf(world)[%q] = value", resource.Name),
+		"end",
+	}
+	for _, line := range lines {
+		g.writeLine(line)
+	}
+}
+
+// writeResourcePrelude emits Module.Resources and the per-world storage.
+// Worlds are weak keys, so a world's resources are collected with it.
+func (g *Generator) writeResourcePrelude() {
+	lines := []string{
+		"Module.Resources = {}",
+		"",
+		`local worldResources: { [any]: { [string]: any } } = setmetatable({}, { __mode = "k" }) :: any`,
+		"",
+		"local function resourcesOf(world: any): { [string]: any }",
+		"    local values = worldResources[world]",
+		"    if values == nil then",
+		"        values = {}",
+		"        worldResources[world] = values",
+		"    end",
+		"    return values",
+		"end",
+		"",
+		"local function getResource(world: any, name: string): any",
+		"    local values = resourcesOf(world)",
+		"    if values[name] == nil then",
+		"        values[name] = Module.Resources[name].new()",
+		"    end",
+		"    return values[name]",
+		"end",
+		"",
+	}
+	for _, line := range lines {
+		g.writeLine(line)
+	}
+}
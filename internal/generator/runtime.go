@@ -0,0 +1,16 @@
+package generator
+
+import (
+	_ "embed"
+)
+
+// RuntimeFile is the name the runtime is written under, next to the
+// generated module
+const RuntimeFile = "ejecs_runtime.luau"
+
+// Runtime is the source of ejecs_runtime.luau, the Luau library that wraps a
+// generated module in a World describing its components and systems. It reads
+// the Module.Schema table of SchemaVersion.
+//
+//go:embed ejecs_runtime.luau
+var Runtime string
@@ -0,0 +1,398 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/analysis"
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/eval"
+)
+
+// schedulerEvents maps the event names accepted by frequency to the RunService
+// events the scheduler connects to. frame is the default and runs on Heartbeat.
+var schedulerEvents = map[string]string{
+	"frame":     "Heartbeat",
+	"heartbeat": "Heartbeat",
+	"stepped":   "Stepped",
+	"render":    "RenderStepped",
+}
+
+const frequencyGrammar = "expected fixed(hz), every(seconds), frame, heartbeat, stepped or render"
+
+// schedule is a resolved system frequency. interval is empty for systems that
+// run on every event.
+type schedule struct {
+	event    string // RunService event
+	interval string // Luau expression for the seconds between runs
+	fixed    bool   // Run in fixed steps of interval, catching up when behind
+}
+
+// Luau returns the schedule as the frequency table read by Module.Scheduler
+func (s *schedule) Luau() string {
+	if s.interval == "" {
+		return fmt.Sprintf("{ event = %q }", s.event)
+	}
+	return fmt.Sprintf("{ event = %q, interval = %s, fixed = %t }", s.event, s.interval, s.fixed)
+}
+
+// hasSystems reports whether the generated module registers any system,
+// including the built-in Replication system
+func hasSystems(program *ast.Program) bool {
+	for _, stmt := range program.Statements {
+		if _, ok := stmt.(*ast.System); ok {
+			return true
+		}
+	}
+	return len(componentsWithAttribute(program, "replicated")) > 0
+}
+
+// resolveFrequency interprets a system's frequency clause. It accepts
+// fixed(hz) and every(seconds), each with an optional event argument, a bare
+// rate as shorthand for fixed(hz), and the event names on their own. A system
+// in a phase runs on the RunService event of that name, so its frequency
+// can't name another event.
+func (g *Generator) resolveFrequency(system *ast.System) (*schedule, error) {
+	event := "Heartbeat"
+	if system.Phase != "" {
+		event = system.Phase
+	}
+	if system.Frequency == nil {
+		return &schedule{event: event}, nil
+	}
+	invalid := fmt.Errorf("system %s: invalid frequency %s (%s)", system.Name, system.Frequency.String(), frequencyGrammar)
+	named := func(name string) (*schedule, error) {
+		if system.Phase != "" {
+			return nil, fmt.Errorf("system %s: phase %s runs on RunService.%s, frequency cannot name the event %s", system.Name, system.Phase, system.Phase, name)
+		}
+		return &schedule{event: schedulerEvents[name]}, nil
+	}
+
+	switch f := system.Frequency.(type) {
+	case *ast.Identifier:
+		if _, ok := schedulerEvents[f.Value]; ok {
+			return named(f.Value)
+		}
+	case *ast.CallExpression:
+		fn, ok := f.Function.(*ast.Identifier)
+		if !ok || (fn.Value != "fixed" && fn.Value != "every") {
+			return nil, invalid
+		}
+		if len(f.Arguments) < 1 || len(f.Arguments) > 2 {
+			return nil, fmt.Errorf("system %s: %s() expects a rate and an optional event, got %d argument(s)", system.Name, fn.Value, len(f.Arguments))
+		}
+		s := &schedule{event: event, fixed: fn.Value == "fixed"}
+		if len(f.Arguments) == 2 {
+			ident, ok := f.Arguments[1].(*ast.Identifier)
+			if !ok || schedulerEvents[ident.Value] == "" {
+				return nil, fmt.Errorf("system %s: unknown event %s (expected frame, heartbeat, stepped or render)", system.Name, f.Arguments[1].String())
+			}
+			named, err := named(ident.Value)
+			if err != nil {
+				return nil, err
+			}
+			s.event = named.event
+		}
+		value, err := g.positiveConstant(system, fn.Value, f.Arguments[0])
+		if err != nil {
+			return nil, err
+		}
+		if s.fixed {
+			s.interval = "1 / " + value.Luau()
+		} else {
+			s.interval = value.Luau()
+		}
+		return s, nil
+	}
+
+	// A bare rate such as `frequency: 60` means fixed(60)
+	value, err := eval.Eval(system.Frequency, g.constants)
+	if errors.Is(err, eval.ErrNotConstant) || (err == nil && value.Kind != eval.Number) {
+		return nil, invalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("system %s: frequency: %v", system.Name, err)
+	}
+	if value.Number <= 0 {
+		return nil, fmt.Errorf("system %s: frequency must be positive, got %s", system.Name, value.Luau())
+	}
+	return &schedule{event: event, interval: "1 / " + value.Luau(), fixed: true}, nil
+}
+
+// positiveConstant folds the rate argument of fixed() or every()
+func (g *Generator) positiveConstant(system *ast.System, fn string, expr ast.Expression) (eval.Value, error) {
+	value, err := eval.Eval(expr, g.constants)
+	if errors.Is(err, eval.ErrNotConstant) {
+		return eval.Value{}, fmt.Errorf("system %s: %s() rate %s is not a compile-time constant", system.Name, fn, expr.String())
+	}
+	if err != nil {
+		return eval.Value{}, fmt.Errorf("system %s: %s(): %v", system.Name, fn, err)
+	}
+	if value.Kind != eval.Number || value.Number <= 0 {
+		return eval.Value{}, fmt.Errorf("system %s: %s() expects a positive number, got %s", system.Name, fn, value.Luau())
+	}
+	return value, nil
+}
+
+// schedulerPhases are the frame stages a system can be placed in with
+// `phase:`, in the order RunService fires them each frame. Each is also the
+// name of the RunService event the system runs on.
+var schedulerPhases = []string{"PreRender", "PreAnimation", "PreSimulation", "PostSimulation"}
+
+// systemOrder resolves the order systems run in. Systems in an earlier phase
+// run before systems in a later one, and after/before clauses and emitted
+// events add further edges. Among the systems whose dependencies have run, the lowest priority
+// goes first, then declaration order.
+func (g *Generator) systemOrder(program *ast.Program) ([]*ast.System, error) {
+	var systems []*ast.System
+	index := make(map[string]int)
+	for _, stmt := range program.Statements {
+		system, ok := stmt.(*ast.System)
+		if !ok {
+			continue
+		}
+		if _, exists := index[system.Name]; exists {
+			return nil, fmt.Errorf("system %s is declared more than once", system.Name)
+		}
+		index[system.Name] = len(systems)
+		systems = append(systems, system)
+	}
+
+	priorities := make([]float64, len(systems))
+	phases := make([]int, len(systems))
+	for i, system := range systems {
+		priority, err := g.systemPriority(system)
+		if err != nil {
+			return nil, err
+		}
+		priorities[i] = priority
+		phases[i] = -1
+		if system.Phase != "" {
+			phases[i] = indexOf(schedulerPhases, system.Phase)
+			if phases[i] < 0 {
+				return nil, fmt.Errorf("system %s: unknown phase %s (expected %s)", system.Name, system.Phase, strings.Join(schedulerPhases, ", "))
+			}
+		}
+	}
+
+	// edges[a][b] means a runs before b
+	edges := make([]map[int]bool, len(systems))
+	for i := range edges {
+		edges[i] = make(map[int]bool)
+	}
+	for i, system := range systems {
+		for _, clause := range []struct {
+			keyword string
+			names   []string
+		}{{"after", system.After}, {"before", system.Before}} {
+			for _, name := range clause.names {
+				other, ok := index[name]
+				if !ok {
+					return nil, fmt.Errorf("system %s: %s unknown system %s", system.Name, clause.keyword, name)
+				}
+				if clause.keyword == "after" {
+					edges[other][i] = true
+				} else {
+					edges[i][other] = true
+				}
+			}
+		}
+		// Emitters run before the systems that read their events
+		for _, event := range system.Emits {
+			for j, reader := range systems {
+				if j != i && containsString(reader.Reads, event) {
+					edges[i][j] = true
+				}
+			}
+		}
+		for j := range systems {
+			if phases[i] >= 0 && phases[j] > phases[i] {
+				edges[i][j] = true
+			}
+		}
+	}
+
+	// Kahn's algorithm, picking the lowest priority among the ready systems
+	incoming := make([]int, len(systems))
+	for _, successors := range edges {
+		for j := range successors {
+			incoming[j]++
+		}
+	}
+	done := make([]bool, len(systems))
+	var order []*ast.System
+	for len(order) < len(systems) {
+		next := -1
+		for i := range systems {
+			if !done[i] && incoming[i] == 0 && (next < 0 || priorities[i] < priorities[next]) {
+				next = i
+			}
+		}
+		if next < 0 {
+			return nil, orderingCycle(systems, edges, done)
+		}
+		done[next] = true
+		order = append(order, systems[next])
+		for j := range edges[next] {
+			incoming[j]--
+		}
+	}
+	return order, nil
+}
+
+// systemPriority folds a system's priority. Systems without one count as 0.
+func (g *Generator) systemPriority(system *ast.System) (float64, error) {
+	if system.Priority == nil {
+		return 0, nil
+	}
+	value, err := eval.Eval(system.Priority, g.constants)
+	if err != nil || value.Kind != eval.Number {
+		return 0, fmt.Errorf("system %s: priority %s must be a constant number", system.Name, system.Priority.String())
+	}
+	return value.Number, nil
+}
+
+// warnUnorderedWrites warns about systems of equal priority that write the
+// same component without an after or before clause between them. Their
+// relative order then only depends on where they are declared.
+func (g *Generator) warnUnorderedWrites(systems []*ast.System, report *analysis.Report) {
+	byName := make(map[string]*ast.System, len(systems))
+	for _, system := range systems {
+		byName[system.Name] = system
+	}
+	for _, c := range report.Conflicts {
+		a, b := byName[c.A], byName[c.B]
+		if !c.WriteWrite || analysis.Ordered(a, b) {
+			continue
+		}
+		priorityA, _ := g.systemPriority(a) // Already checked by systemOrder
+		priorityB, _ := g.systemPriority(b)
+		if priorityA == priorityB {
+			g.warnings = append(g.warnings, fmt.Sprintf(
+				"systems %s and %s both write %s at priority %s; add after or before to fix their order",
+				c.A, c.B, strings.Join(c.Components, ", "), eval.NumberValue(priorityA).Luau()))
+		}
+	}
+}
+
+// orderingCycle describes a cycle among the systems left unscheduled. Each of
+// them has an unscheduled predecessor, so walking predecessors must revisit a
+// system.
+func orderingCycle(systems []*ast.System, edges []map[int]bool, done []bool) error {
+	current := 0
+	for done[current] {
+		current++
+	}
+	seen := make(map[int]int) // system -> position in path
+	var path []int
+	for {
+		if at, ok := seen[current]; ok {
+			path = path[at:]
+			break
+		}
+		seen[current] = len(path)
+		path = append(path, current)
+		for i := range systems {
+			if !done[i] && edges[i][current] {
+				current = i
+				break
+			}
+		}
+	}
+
+	// path follows edges backwards; list the cycle in run order
+	names := make([]string, 0, len(path)+1)
+	for i := len(path) - 1; i >= 0; i-- {
+		names = append(names, systems[path[i]].Name)
+	}
+	names = append(names, names[0])
+	return fmt.Errorf("system ordering cycle: %s", strings.Join(names, " -> "))
+}
+
+// generateScheduler emits Module.Scheduler, which runs Module.Systems at their
+// declared frequency. The caller supplies run(system, dt), which executes one
+// tick of a system against its world. Order lists the systems of batches in
+// sequence; Batches groups those that can run in parallel.
+func (g *Generator) generateScheduler(batches [][]string) {
+	var order, groups []string
+	for _, batch := range batches {
+		quoted := make([]string, len(batch))
+		for i, name := range batch {
+			quoted[i] = fmt.Sprintf("%q", name)
+		}
+		order = append(order, quoted...)
+		groups = append(groups, "{ "+strings.Join(quoted, ", ")+" }")
+	}
+
+	lines := []string{
+		"Module.Scheduler = {}",
+		fmt.Sprintf("Module.Scheduler.Order = { %s }", strings.Join(order, ", ")),
+		fmt.Sprintf("Module.Scheduler.Batches = { %s }", strings.Join(groups, ", ")),
+		"Module.Scheduler.MaxSteps = 5",
+		"Module.Scheduler.elapsed = {}",
+		"Module.Scheduler.current = nil :: string? -- System being run",
+		"Module.Scheduler.ticks = 0",
+		"",
+		"-- Advances the systems bound to a RunService event by dt seconds and calls",
+		"-- run(system, dt) for every tick that is due. Fixed systems run in steps of",
+		"-- their interval and catch up at most MaxSteps times per event.",
+		"function Module.Scheduler.step(event: string, dt: number, run: (system: any, dt: number) -> ())",
+		"    local function tick(name: string, dt: number)",
+		"        Module.Scheduler.current = name",
+		"        Module.Scheduler.ticks += 1",
+		"        run(Module.Systems[name], dt)",
+		"        Module.Scheduler.current = nil",
+		"    end",
+		"    for _, name in ipairs(Module.Scheduler.Order) do",
+		"        local system = Module.Systems[name]",
+		`        local frequency = system.frequency or { event = "Heartbeat" }`,
+		"        if frequency.event == event then",
+		"            if frequency.interval == nil then",
+		"                tick(name, dt)",
+		"            else",
+		"                local elapsed = (Module.Scheduler.elapsed[name] or 0) + dt",
+		"                if frequency.fixed then",
+		"                    local steps = 0",
+		"                    while elapsed >= frequency.interval and steps < Module.Scheduler.MaxSteps do",
+		"                        tick(name, frequency.interval)",
+		"                        elapsed -= frequency.interval",
+		"                        steps += 1",
+		"                    end",
+		"                    -- Drop the backlog instead of falling further behind",
+		"                    elapsed %= frequency.interval",
+		"                elseif elapsed >= frequency.interval then",
+		"                    tick(name, elapsed)",
+		"                    elapsed = 0",
+		"                end",
+		"                Module.Scheduler.elapsed[name] = elapsed",
+		"            end",
+		"        end",
+		"    end",
+		"end",
+		"",
+		"-- Connects step to RunService and returns a function that disconnects it",
+		"function Module.Scheduler.start(run: (system: any, dt: number) -> ()): () -> ()",
+		`    local RunService = game:GetService("RunService")`,
+		"    local connections = {",
+		`        RunService.Stepped:Connect(function(_, dt) Module.Scheduler.step("Stepped", dt, run) end),`,
+		`        RunService.Heartbeat:Connect(function(dt) Module.Scheduler.step("Heartbeat", dt, run) end),`,
+		`        RunService.PreAnimation:Connect(function(dt) Module.Scheduler.step("PreAnimation", dt, run) end),`,
+		`        RunService.PreSimulation:Connect(function(dt) Module.Scheduler.step("PreSimulation", dt, run) end),`,
+		`        RunService.PostSimulation:Connect(function(dt) Module.Scheduler.step("PostSimulation", dt, run) end),`,
+		"    }",
+		"    if RunService:IsClient() then",
+		`        table.insert(connections, RunService.RenderStepped:Connect(function(dt) Module.Scheduler.step("RenderStepped", dt, run) end))`,
+		`        table.insert(connections, RunService.PreRender:Connect(function(dt) Module.Scheduler.step("PreRender", dt, run) end))`,
+		"    end",
+		"    return function()",
+		"        for _, connection in connections do",
+		"            connection:Disconnect()",
+		"        end",
+		"    end",
+		"end",
+	}
+	for _, line := range lines {
+		g.writeLine(line)
+	}
+}
@@ -0,0 +1,151 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/analysis"
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// SchemaVersion is the version of the Module.Schema layout. ejecs_runtime
+// refuses modules with another version.
+const SchemaVersion = 1
+
+// writeSchema emits Module.Schema, which describes the components and systems
+// of the module for reflection at runtime. Field and query lists keep their
+// declaration order.
+func (g *Generator) writeSchema(program *ast.Program) {
+	g.writeLine("-- Describes the declarations of this module, read by ejecs_runtime")
+	g.writeLine("Module.Schema = {")
+	g.indent++
+	g.writeLine(fmt.Sprintf("version = %d,", SchemaVersion))
+
+	var comps []*ast.Component
+	var systems []*ast.System
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			if !n.IsGeneric() {
+				comps = append(comps, n)
+			}
+		case *ast.System:
+			systems = append(systems, n)
+		}
+	}
+
+	g.openSchemaTable("components", len(comps))
+	for _, comp := range comps {
+		g.writeLine(comp.Name + " = {")
+		g.indent++
+		g.writeLine(fmt.Sprintf("name = %q,", comp.Name))
+		if comp.Extends != "" {
+			g.writeLine(fmt.Sprintf("extends = %q,", comp.Extends))
+		}
+		g.writeLine(fmt.Sprintf("attributes = %s,", luauStrings(comp.Attributes)))
+		g.writeLine("fields = {")
+		g.indent++
+		for _, field := range comp.Fields {
+			var attrs []string
+			for _, attr := range field.Attributes {
+				attrs = append(attrs, attr.Name)
+			}
+			g.writeLine(fmt.Sprintf("{ name = %q, type = %q, optional = %t, attributes = %s },",
+				field.Name, schemaType(field), field.Optional, luauStrings(attrs)))
+		}
+		g.indent--
+		g.writeLine("},")
+		g.indent--
+		g.writeLine("},")
+	}
+	g.closeSchemaTable(len(comps))
+
+	g.openSchemaTable("systems", len(systems))
+	for _, system := range systems {
+		var query []string
+		if system.Query != nil {
+			query = system.Query.Components
+		}
+		access := analysis.AccessOf(system)
+		g.writeLine(system.Name + " = {")
+		g.indent++
+		g.writeLine(fmt.Sprintf("name = %q,", system.Name))
+		if system.Phase != "" {
+			g.writeLine(fmt.Sprintf("phase = %q,", system.Phase))
+		}
+		g.writeLine(fmt.Sprintf("query = %s,", luauStrings(query)))
+		g.writeLine(fmt.Sprintf("reads = %s,", luauStrings(access.Reads)))
+		g.writeLine(fmt.Sprintf("writes = %s,", luauStrings(access.Writes)))
+		params := make([]string, len(system.Parameters))
+		for i, param := range system.Parameters {
+			params[i] = fmt.Sprintf("{ name = %q, type = %q }", param.Name, param.Type)
+		}
+		if len(params) == 0 {
+			g.writeLine("parameters = {},")
+		} else {
+			g.writeLine(fmt.Sprintf("parameters = { %s },", strings.Join(params, ", ")))
+		}
+		g.indent--
+		g.writeLine("},")
+	}
+	g.closeSchemaTable(len(systems))
+
+	g.indent--
+	g.writeLine("}")
+}
+
+// openSchemaTable starts the Module.Schema entry key, written as {} when it
+// has no entries
+func (g *Generator) openSchemaTable(key string, entries int) {
+	if entries == 0 {
+		g.writeLine(key + " = {},")
+		return
+	}
+	g.writeLine(key + " = {")
+	g.indent++
+}
+
+// closeSchemaTable ends an entry started by openSchemaTable
+func (g *Generator) closeSchemaTable(entries int) {
+	if entries > 0 {
+		g.indent--
+		g.writeLine("},")
+	}
+}
+
+// hasSchema reports whether the program declares a component, other than a
+// generic template, or a system to describe in Module.Schema
+func hasSchema(program *ast.Program) bool {
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			if !n.IsGeneric() {
+				return true
+			}
+		case *ast.System:
+			return true
+		}
+	}
+	return false
+}
+
+// schemaType returns the EJECS spelling of a field's type, such as
+// table<string, number>
+func schemaType(field *ast.Field) string {
+	if field.Type == "table" && field.MapKeyType != "" {
+		return fmt.Sprintf("table<%s, %s>", field.MapKeyType, field.MapValueType)
+	}
+	return field.Type
+}
+
+// luauStrings returns a Luau array of quoted strings
+func luauStrings(items []string) string {
+	if len(items) == 0 {
+		return "{}"
+	}
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	return "{ " + strings.Join(quoted, ", ") + " }"
+}
@@ -0,0 +1,301 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/wire"
+)
+
+// maxStringLength is the longest string, in bytes, the u16 length prefix of
+// serialized strings can describe
+const maxStringLength = 65535
+
+// replicatedMembers are the functions added to @replicated components
+var replicatedMembers = []string{"size", "serialize", "deserialize"}
+
+// hasAttribute reports whether a component carries the given attribute
+func hasAttribute(comp *ast.Component, name string) bool {
+	for _, attr := range comp.Attributes {
+		if attr == name {
+			return true
+		}
+	}
+	return false
+}
+
+// componentsWithAttribute returns the components carrying an attribute, in
+// declaration order
+func componentsWithAttribute(program *ast.Program, name string) []*ast.Component {
+	var comps []*ast.Component
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok && !comp.IsGeneric() && hasAttribute(comp, name) {
+			comps = append(comps, comp)
+		}
+	}
+	return comps
+}
+
+// headerType returns the buffer read/write suffix for a header of n bytes
+func headerType(n int) string {
+	switch n {
+	case 1:
+		return "u8"
+	case 2:
+		return "u16"
+	default:
+		return "u32"
+	}
+}
+
+// bitMask returns the Luau literal for a single header bit
+func bitMask(bit int) string {
+	return fmt.Sprintf("%d", uint32(1)<<bit)
+}
+
+// generateSerializer emits size/serialize/deserialize for a @replicated
+// component using the layout defined by the wire package.
+func (g *Generator) generateSerializer(comp *ast.Component) error {
+	layout, err := wire.NewLayout(comp)
+	if err != nil {
+		return err
+	}
+	path := "Module.Components." + comp.Name
+
+	g.generateSizeFunction(path, comp.Name, layout)
+	g.writeLine("")
+	g.generateSerializeFunction(path, comp.Name, layout)
+	g.writeLine("")
+	g.generateDeserializeFunction(path, comp.Name, layout)
+	return nil
+}
+
+func (g *Generator) generateSizeFunction(path, name string, layout *wire.Layout) {
+	fixed := layout.HeaderSize()
+	for _, field := range layout.Fields {
+		if !field.Optional {
+			fixed += field.Kind.Size()
+		}
+	}
+
+	g.writeLine(fmt.Sprintf("function %s.size(component: %s): number", path, name))
+	g.indent++
+	g.writeLine(fmt.Sprintf("local size = %d", fixed))
+	for _, field := range layout.Fields {
+		access := "component." + field.Name
+		if field.Kind == wire.Bool {
+			continue
+		}
+		extra := ""
+		if field.Kind == wire.String {
+			extra = " + #" + access
+		}
+		if field.Optional {
+			g.writeLine(fmt.Sprintf("if %s ~= nil then", access))
+			g.indent++
+			g.writeLine(fmt.Sprintf("size += %d%s", field.Kind.Size(), extra))
+			g.indent--
+			g.writeLine("end")
+		} else if extra != "" {
+			g.writeLine("size += #" + access)
+		}
+	}
+	g.writeLine("return size")
+	g.indent--
+	g.writeLine("end")
+}
+
+func (g *Generator) generateSerializeFunction(path, name string, layout *wire.Layout) {
+	g.writeLine(fmt.Sprintf("function %s.serialize(component: %s, buf: buffer, offset: number): number", path, name))
+	g.indent++
+
+	if layout.HeaderSize() > 0 {
+		g.writeLine("local bits = 0")
+		for _, field := range layout.Fields {
+			access := "component." + field.Name
+			if field.PresenceBit >= 0 {
+				g.writeLine(fmt.Sprintf("if %s ~= nil then", access))
+				g.indent++
+				g.writeLine(fmt.Sprintf("bits = bit32.bor(bits, %s)", bitMask(field.PresenceBit)))
+				g.indent--
+				g.writeLine("end")
+			}
+			if field.ValueBit >= 0 {
+				g.writeLine(fmt.Sprintf("if %s then", access))
+				g.indent++
+				g.writeLine(fmt.Sprintf("bits = bit32.bor(bits, %s)", bitMask(field.ValueBit)))
+				g.indent--
+				g.writeLine("end")
+			}
+		}
+		g.writeLine(fmt.Sprintf("buffer.write%s(buf, offset, bits)", headerType(layout.HeaderSize())))
+		g.writeLine(fmt.Sprintf("offset += %d", layout.HeaderSize()))
+	}
+
+	for _, field := range layout.Fields {
+		if field.Kind == wire.Bool {
+			continue
+		}
+		access := "component." + field.Name
+		if field.Optional {
+			g.writeLine(fmt.Sprintf("if %s ~= nil then", access))
+			g.indent++
+		}
+		g.writeFieldWrite(access, field.Kind)
+		if field.Optional {
+			g.indent--
+			g.writeLine("end")
+		}
+	}
+
+	g.writeLine("return offset")
+	g.indent--
+	g.writeLine("end")
+}
+
+// writeFieldWrite emits the buffer writes for one value and advances offset
+func (g *Generator) writeFieldWrite(access string, kind wire.Kind) {
+	switch kind {
+	case wire.F32, wire.F64, wire.I32:
+		g.writeLine(fmt.Sprintf("buffer.write%s(buf, offset, %s)", scalarType(kind), access))
+	case wire.String:
+		// The u16 length prefix can't describe longer strings
+		g.writeLine(fmt.Sprintf("if #%s > %d then", access, maxStringLength))
+		g.indent++
+		g.writeLine(fmt.Sprintf(`error("%s: strings are limited to %d bytes, got " .. #%s)`, access, maxStringLength, access))
+		g.indent--
+		g.writeLine("end")
+		g.writeLine(fmt.Sprintf("buffer.writeu16(buf, offset, #%s)", access))
+		g.writeLine(fmt.Sprintf("buffer.writestring(buf, offset + 2, %s)", access))
+		g.writeLine(fmt.Sprintf("offset += #%s", access))
+	case wire.Vector2:
+		g.writeF32s(access+".X", access+".Y")
+	case wire.Vector3:
+		g.writeF32s(access+".X", access+".Y", access+".Z")
+	case wire.CFrame:
+		g.writeLine("do")
+		g.indent++
+		g.writeLine(fmt.Sprintf("local rx, ry, rz = %s:ToEulerAnglesXYZ()", access))
+		g.writeF32s(access+".X", access+".Y", access+".Z", "rx", "ry", "rz")
+		g.indent--
+		g.writeLine("end")
+	case wire.Color3:
+		for i, channel := range []string{"R", "G", "B"} {
+			g.writeLine(fmt.Sprintf("buffer.writeu8(buf, %s, math.clamp(math.round(%s.%s * 255), 0, 255))", offsetPlus(i), access, channel))
+		}
+	case wire.UDim:
+		g.writeLine(fmt.Sprintf("buffer.writef32(buf, offset, %s.Scale)", access))
+		g.writeLine(fmt.Sprintf("buffer.writei32(buf, offset + 4, %s.Offset)", access))
+	case wire.UDim2:
+		g.writeLine(fmt.Sprintf("buffer.writef32(buf, offset, %s.X.Scale)", access))
+		g.writeLine(fmt.Sprintf("buffer.writei32(buf, offset + 4, %s.X.Offset)", access))
+		g.writeLine(fmt.Sprintf("buffer.writef32(buf, offset + 8, %s.Y.Scale)", access))
+		g.writeLine(fmt.Sprintf("buffer.writei32(buf, offset + 12, %s.Y.Offset)", access))
+	}
+	g.writeLine(fmt.Sprintf("offset += %d", kind.Size()))
+}
+
+// writeF32s emits consecutive f32 writes starting at offset
+func (g *Generator) writeF32s(values ...string) {
+	for i, v := range values {
+		g.writeLine(fmt.Sprintf("buffer.writef32(buf, %s, %s)", offsetPlus(i*4), v))
+	}
+}
+
+// offsetPlus returns the Luau expression for offset advanced by n bytes
+func offsetPlus(n int) string {
+	if n == 0 {
+		return "offset"
+	}
+	return fmt.Sprintf("offset + %d", n)
+}
+
+func (g *Generator) generateDeserializeFunction(path, name string, layout *wire.Layout) {
+	g.writeLine(fmt.Sprintf("function %s.deserialize(buf: buffer, offset: number): (%s, number)", path, name))
+	g.indent++
+	// Start from defaults so fields that are not replicated keep a sane value
+	g.writeLine(fmt.Sprintf("local component = %s.new()", path))
+
+	if layout.HeaderSize() > 0 {
+		g.writeLine(fmt.Sprintf("local bits = buffer.read%s(buf, offset)", headerType(layout.HeaderSize())))
+		g.writeLine(fmt.Sprintf("offset += %d", layout.HeaderSize()))
+	}
+
+	for _, field := range layout.Fields {
+		target := "component." + field.Name
+		if field.Optional {
+			g.writeLine(fmt.Sprintf("if bit32.btest(bits, %s) then", bitMask(field.PresenceBit)))
+			g.indent++
+		}
+		if field.Kind == wire.Bool {
+			g.writeLine(fmt.Sprintf("%s = bit32.btest(bits, %s)", target, bitMask(field.ValueBit)))
+		} else {
+			g.writeFieldRead(target, field.Kind)
+		}
+		if field.Optional {
+			g.indent--
+			g.writeLine("else")
+			g.indent++
+			g.writeLine(target + " = nil")
+			g.indent--
+			g.writeLine("end")
+		}
+	}
+
+	g.writeLine("return component, offset")
+	g.indent--
+	g.writeLine("end")
+}
+
+// writeFieldRead emits the buffer reads for one value and advances offset
+func (g *Generator) writeFieldRead(target string, kind wire.Kind) {
+	f32 := func(i int) string {
+		return fmt.Sprintf("buffer.readf32(buf, %s)", offsetPlus(i*4))
+	}
+
+	switch kind {
+	case wire.F32, wire.F64, wire.I32:
+		g.writeLine(fmt.Sprintf("%s = buffer.read%s(buf, offset)", target, scalarType(kind)))
+	case wire.String:
+		g.writeLine("do")
+		g.indent++
+		g.writeLine("local length = buffer.readu16(buf, offset)")
+		g.writeLine(fmt.Sprintf("%s = buffer.readstring(buf, offset + 2, length)", target))
+		g.writeLine("offset += length")
+		g.indent--
+		g.writeLine("end")
+	case wire.Vector2:
+		g.writeLine(fmt.Sprintf("%s = Vector2.new(%s, %s)", target, f32(0), f32(1)))
+	case wire.Vector3:
+		g.writeLine(fmt.Sprintf("%s = Vector3.new(%s, %s, %s)", target, f32(0), f32(1), f32(2)))
+	case wire.CFrame:
+		g.writeLine(fmt.Sprintf("%s = CFrame.new(%s, %s, %s)", target, f32(0), f32(1), f32(2)))
+		g.indent++
+		g.writeLine(fmt.Sprintf("* CFrame.fromEulerAnglesXYZ(%s, %s, %s)", f32(3), f32(4), f32(5)))
+		g.indent--
+	case wire.Color3:
+		g.writeLine(fmt.Sprintf("%s = Color3.fromRGB(buffer.readu8(buf, offset), buffer.readu8(buf, offset + 1), buffer.readu8(buf, offset + 2))", target))
+	case wire.UDim:
+		g.writeLine(fmt.Sprintf("%s = UDim.new(buffer.readf32(buf, offset), buffer.readi32(buf, offset + 4))", target))
+	case wire.UDim2:
+		g.writeLine(fmt.Sprintf("%s = UDim2.new(", target))
+		g.indent++
+		g.writeLine("buffer.readf32(buf, offset), buffer.readi32(buf, offset + 4),")
+		g.writeLine("buffer.readf32(buf, offset + 8), buffer.readi32(buf, offset + 12)")
+		g.indent--
+		g.writeLine(")")
+	}
+	g.writeLine(fmt.Sprintf("offset += %d", kind.Size()))
+}
+
+// scalarType returns the buffer library suffix for numeric kinds
+func scalarType(kind wire.Kind) string {
+	switch kind {
+	case wire.F32:
+		return "f32"
+	case wire.F64:
+		return "f64"
+	default:
+		return "i32"
+	}
+}
@@ -0,0 +1,229 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/checker"
+)
+
+// typeofName returns the name Luau's typeof() reports for values of an EJECS
+// type, or "" when the type cannot be checked at runtime (e.g. "any").
+func typeofName(t string) string {
+	switch t {
+	case "number", "int", "float":
+		return "number"
+	case "string", "boolean", "table":
+		return t
+	default:
+		if checker.IsDataType(t) {
+			return t
+		}
+		if enumName(t) != "" {
+			return "EnumItem"
+		}
+		return ""
+	}
+}
+
+// checkConstraints verifies that a field's constraint attributes are applied to
+// a compatible type with the right number of arguments.
+func checkConstraints(comp *ast.Component, field *ast.Field) error {
+	for _, attr := range field.Attributes {
+		var want int
+		switch attr.Name {
+		case "min", "max":
+			want = 1
+		case "range":
+			want = 2
+		case "maxLength":
+			want = 1
+			if field.Type != "string" && field.Type != "table" {
+				return fmt.Errorf("component %s: field '%s': @maxLength requires a string or table field, got %s", comp.Name, field.Name, field.Type)
+			}
+		default:
+			continue // Not a constraint
+		}
+		if len(attr.Arguments) != want {
+			return fmt.Errorf("component %s: field '%s': @%s expects %d argument(s), got %d", comp.Name, field.Name, attr.Name, want, len(attr.Arguments))
+		}
+		if attr.Name != "maxLength" && typeofName(field.Type) != "number" {
+			return fmt.Errorf("component %s: field '%s': @%s requires a numeric field, got %s", comp.Name, field.Name, attr.Name, field.Type)
+		}
+	}
+	return nil
+}
+
+// generateValidator emits Module.Components.<Name>.validate(value), which
+// checks untrusted data against the component schema and returns false plus
+// the path of the first failing field.
+func (g *Generator) generateValidator(comp *ast.Component) error {
+	g.writeLine(fmt.Sprintf("function Module.Components.%s.validate(value: any): (boolean, string?)", comp.Name))
+	g.indent++
+	g.writeLine(`if type(value) ~= "table" then`)
+	g.indent++
+	g.writeLine(fmt.Sprintf(`return false, "%s: expected table, got " .. typeof(value)`, comp.Name))
+	g.indent--
+	g.writeLine("end")
+
+	for _, field := range comp.Fields {
+		if err := checkConstraints(comp, field); err != nil {
+			return err
+		}
+		if err := g.generateFieldCheck(field); err != nil {
+			return err
+		}
+	}
+
+	g.writeLine("return true, nil")
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
+
+// generateFieldCheck emits the type and constraint checks for a single field
+func (g *Generator) generateFieldCheck(field *ast.Field) error {
+	access := "value." + field.Name
+	expected := typeofName(field.Type)
+
+	if field.Optional {
+		g.writeLine(fmt.Sprintf("if %s ~= nil then", access))
+		g.indent++
+	} else if expected == "" && field.Type != "any" {
+		// Unknown types can't be checked with typeof, but required fields must exist
+		g.writeLine(fmt.Sprintf("if %s == nil then", access))
+		g.indent++
+		g.writeLine(fmt.Sprintf(`return false, "%s: expected %s, got nil"`, field.Name, field.Type))
+		g.indent--
+		g.writeLine("end")
+	}
+
+	if expected != "" {
+		g.writeTypeCheck(access, expected, fmt.Sprintf("%q", field.Name), field.Type == "int")
+		g.writeEnumCheck(access, field.Type, fmt.Sprintf("%q", field.Name))
+	}
+
+	if field.Type == "table" {
+		g.writeLine(fmt.Sprintf("for key, item in pairs(%s) do", access))
+		g.indent++
+		path := fmt.Sprintf(`"%s[" .. tostring(key) .. "]"`, field.Name)
+		if keyType := typeofName(field.MapKeyType); keyType != "" {
+			g.writeTypeCheck("key", keyType, path, field.MapKeyType == "int")
+		}
+		if valueType := typeofName(field.MapValueType); valueType != "" {
+			g.writeTypeCheck("item", valueType, path, field.MapValueType == "int")
+			g.writeEnumCheck("item", field.MapValueType, path)
+		}
+		g.indent--
+		g.writeLine("end")
+	}
+
+	for _, attr := range field.Attributes {
+		if err := g.writeConstraintCheck(access, field, attr); err != nil {
+			return err
+		}
+	}
+
+	if field.Optional {
+		g.indent--
+		g.writeLine("end")
+	}
+	return nil
+}
+
+// writeTypeCheck emits a typeof() guard; path is a Luau string expression
+func (g *Generator) writeTypeCheck(access, expected, path string, integer bool) {
+	g.writeLine(fmt.Sprintf("if typeof(%s) ~= %q then", access, expected))
+	g.indent++
+	g.writeLine(fmt.Sprintf("return false, %s .. typeof(%s)", joinLuauStrings(path, fmt.Sprintf(`": expected %s, got "`, expected)), access))
+	g.indent--
+	g.writeLine("end")
+	if integer {
+		g.writeLine(fmt.Sprintf("if %s %% 1 ~= 0 then", access))
+		g.indent++
+		g.writeLine(fmt.Sprintf("return false, %s .. tostring(%s)", joinLuauStrings(path, `": expected integer, got "`), access))
+		g.indent--
+		g.writeLine("end")
+	}
+}
+
+// writeEnumCheck emits an EnumType guard when t is a typed enum such as
+// Enum.Material. It must follow the EnumItem typeof() guard.
+func (g *Generator) writeEnumCheck(access, t, path string) {
+	if enumName(t) == "" {
+		return
+	}
+	g.writeLine(fmt.Sprintf("if %s.EnumType ~= %s then", access, t))
+	g.indent++
+	g.writeLine(fmt.Sprintf("return false, %s .. tostring(%s.EnumType)", joinLuauStrings(path, fmt.Sprintf(`": expected %s, got "`, t)), access))
+	g.indent--
+	g.writeLine("end")
+}
+
+// writeConstraintCheck emits the guard for a single constraint attribute
+func (g *Generator) writeConstraintCheck(access string, field *ast.Field, attr *ast.Attribute) error {
+	args := make([]string, len(attr.Arguments))
+	for i, arg := range attr.Arguments {
+		argStr, err := g.generateExpression(arg)
+		if err != nil {
+			return fmt.Errorf("field '%s': @%s: %v", field.Name, attr.Name, err)
+		}
+		args[i] = argStr
+	}
+
+	var cond, message string
+	switch attr.Name {
+	case "min":
+		cond, message = fmt.Sprintf("%s < %s", access, args[0]), "expected at least "+args[0]
+	case "max":
+		cond, message = fmt.Sprintf("%s > %s", access, args[0]), "expected at most "+args[0]
+	case "range":
+		cond = fmt.Sprintf("%s < %s or %s > %s", access, args[0], access, args[1])
+		message = fmt.Sprintf("expected between %s and %s", args[0], args[1])
+	case "maxLength":
+		if field.MapKeyType != "" {
+			g.writeEntryCountCheck(access, field, args[0])
+			return nil
+		}
+		cond, message = fmt.Sprintf("#%s > %s", access, args[0]), "expected length at most "+args[0]
+	default:
+		return nil
+	}
+
+	g.writeLine(fmt.Sprintf("if %s then", cond))
+	g.indent++
+	g.writeLine(fmt.Sprintf("return false, %q", field.Name+": "+message))
+	g.indent--
+	g.writeLine("end")
+	return nil
+}
+
+// writeEntryCountCheck emits the @maxLength guard of a map field. # only
+// counts the array part of a table, so the entries are counted with pairs.
+func (g *Generator) writeEntryCountCheck(access string, field *ast.Field, limit string) {
+	g.writeLine("do")
+	g.indent++
+	g.writeLine("local count = 0")
+	g.writeLine(fmt.Sprintf("for _ in pairs(%s) do", access))
+	g.indent++
+	g.writeLine("count += 1")
+	g.indent--
+	g.writeLine("end")
+	g.writeLine(fmt.Sprintf("if count > %s then", limit))
+	g.indent++
+	g.writeLine(fmt.Sprintf("return false, %q", fmt.Sprintf("%s: expected at most %s entries", field.Name, limit)))
+	g.indent--
+	g.writeLine("end")
+	g.indent--
+	g.writeLine("end")
+}
+
+// joinLuauStrings concatenates two Luau string expressions, merging them into a
+// single literal when the left ends and the right starts with a literal
+func joinLuauStrings(left, right string) string {
+	if strings.HasSuffix(left, `"`) && strings.HasPrefix(right, `"`) {
+		return left[:len(left)-1] + right[1:]
+	}
+	return left + " .. " + right
+}
@@ -22,6 +22,7 @@ var keywords = map[string]token.TokenType{
 	"code":         token.CODE,
 	"pair":         token.PAIR,
 	"table":        token.TABLE,
+	"const":        token.CONST,
 	// "any" is treated as IDENT by lookupIdent
 	// Roblox types are treated as IDENT by lookupIdent
 	"Instance": token.IDENT,
@@ -145,11 +146,25 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.New(token.SLASH, string(l.ch), startLine, startColumn)
 		}
 	case '-':
-		tok = token.New(token.MINUS, string(l.ch), startLine, startColumn)
+		if l.peekChar() == '>' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.New(token.ARROW, literal, startLine, startColumn)
+		} else {
+			tok = token.New(token.MINUS, string(l.ch), startLine, startColumn)
+		}
 	case '*':
 		tok = token.New(token.ASTERISK, string(l.ch), startLine, startColumn)
 	case '.':
-		tok = token.New(token.DOT, string(l.ch), startLine, startColumn)
+		if l.peekChar() == '.' {
+			ch := l.ch
+			l.readChar()
+			literal := string(ch) + string(l.ch)
+			tok = token.New(token.CONCAT, literal, startLine, startColumn)
+		} else {
+			tok = token.New(token.DOT, string(l.ch), startLine, startColumn)
+		}
 	case ',':
 		tok = token.New(token.COMMA, string(l.ch), startLine, startColumn)
 	case ';':
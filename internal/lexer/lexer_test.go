@@ -174,7 +174,7 @@ component Position { // Inline comment
 }
 
 func TestNextToken_Operators(t *testing.T) {
-	input := `+ - * / = == != < <= > >= && ||`
+	input := `+ - * / = == != < <= > >= && || -> ..`
 
 	operatorTests := []struct {
 		expectedTokenType token.TokenType
@@ -193,6 +193,8 @@ func TestNextToken_Operators(t *testing.T) {
 		{token.GTE, ">="},
 		{token.AND, "&&"},
 		{token.OR, "||"},
+		{token.ARROW, "->"},
+		{token.CONCAT, ".."},
 		{token.EOF, ""},
 	}
 
@@ -244,7 +246,7 @@ func TestNextToken_IntFloat(t *testing.T) {
 }
 
 func TestNextToken_Keywords(t *testing.T) {
-	input := `component system relationship true false nil query parameters frequency priority code pair table`
+	input := `component system relationship true false nil query parameters frequency priority code pair table const`
 	tests := []struct {
 		expectedTokenType token.TokenType
 		expectedTokenLit  string
@@ -262,6 +264,7 @@ func TestNextToken_Keywords(t *testing.T) {
 		{token.CODE, "code"},
 		{token.PAIR, "pair"},
 		{token.TABLE, "table"}, // Added table test case here
+		{token.CONST, "const"},
 		{token.EOF, ""},
 	}
 
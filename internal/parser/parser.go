@@ -2,7 +2,7 @@ package parser
 
 import (
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
 
 	"github.com/ejecs/ejecs/internal/ast"
@@ -14,8 +14,11 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	OR          // ||
+	AND         // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	CONCAT      // .. (right associative)
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
@@ -32,12 +35,17 @@ var precedences = map[token.TokenType]int{
 	token.GT:       LESSGREATER,
 	token.LTE:      LESSGREATER,
 	token.GTE:      LESSGREATER,
+	token.OR:       OR,
+	token.AND:      AND,
+	token.CONCAT:   CONCAT,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL, // For function calls
-	token.DOT:      DOT,  // For member access like CFrame.new
+	token.LPAREN:   CALL,  // For function calls
+	token.COLON:    CALL,  // For method calls like a:Lerp(b, t)
+	token.LBRACKET: INDEX, // For indexing like list[1]
+	token.DOT:      DOT,   // For member access like CFrame.new
 }
 
 // Pratt parser function types
@@ -86,6 +94,7 @@ func New(input string) *Parser {
 	p.registerPrefix(token.STRING, p.parseStringLiteral)
 	p.registerPrefix(token.TRUE, p.parseBooleanLiteral)
 	p.registerPrefix(token.FALSE, p.parseBooleanLiteral)
+	p.registerPrefix(token.NULL, p.parseNilLiteral)
 	p.registerPrefix(token.LBRACE, p.parseTableConstructor)  // For table constructors {}
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression) // For ( expression )
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
@@ -94,7 +103,15 @@ func New(input string) *Parser {
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)      // For func()
 	p.registerInfix(token.DOT, p.parseMemberAccessExpression) // For table.field or CFrame.new
-	// Add other infix operators (+, -, *, /, ==, <, etc.) if needed
+	p.registerInfix(token.COLON, p.parseMethodCallExpression) // For obj:Method(args)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)   // For list[1]
+	for _, op := range []token.TokenType{
+		token.PLUS, token.MINUS, token.ASTERISK, token.SLASH,
+		token.EQ, token.NOT_EQ, token.LT, token.GT, token.LTE, token.GTE,
+		token.AND, token.OR, token.CONCAT,
+	} {
+		p.registerInfix(op, p.parseInfixExpression)
+	}
 
 	// Read two tokens, so curToken and peekToken are both set.
 	p.nextToken()
@@ -133,14 +150,33 @@ func (p *Parser) ParseProgram() (*ast.Program, error) {
 
 		switch p.curToken.Type {
 		case token.COMPONENT:
-			stmt, err = p.parseComponent()
-		case token.RELATIONSHIP, token.AT:
-			if p.curTokenIs(token.AT) && !p.peekTokenIs(token.IDENT) {
-				return nil, p.newError("expected identifier after @ for relationship type, got %s", p.peekToken.Type)
+			var comp *ast.Component
+			comp, err = p.parseComponent()
+			if err == nil {
+				err = p.checkMigrations(comp)
 			}
+			stmt = comp
+		case token.AT:
+			stmt, err = p.parseAttributedDeclaration()
+		case token.RELATIONSHIP:
 			stmt, err = p.parseRelationship()
 		case token.SYSTEM:
 			stmt, err = p.parseSystem()
+		case token.CONST:
+			stmt, err = p.parseConst()
+		case token.IDENT:
+			switch p.curToken.Literal {
+			case "event":
+				stmt, err = p.parseEvent()
+			case "resource":
+				stmt, err = p.parseResource()
+			case "prefab":
+				stmt, err = p.parsePrefab()
+			case "observer":
+				stmt, err = p.parseObserver()
+			default:
+				return nil, fmt.Errorf("unexpected identifier %s", p.curToken.Literal)
+			}
 		default:
 			return nil, fmt.Errorf("unexpected token %s", p.curToken.Type)
 		}
@@ -156,9 +192,355 @@ func (p *Parser) ParseProgram() (*ast.Program, error) {
 		p.nextToken()
 	}
 
+	if err := p.resolveGenerics(program); err != nil {
+		return nil, err
+	}
+	if err := p.resolveExtends(program); err != nil {
+		return nil, err
+	}
 	return program, nil
 }
 
+// maxGenericDepth bounds how deeply generic instances may nest, so templates
+// such as `component Box<T> { Box<Box<T>> inner; }` are rejected instead of
+// instantiated forever
+const maxGenericDepth = 16
+
+// resolveGenerics replaces uses of generic components, such as the field type
+// or query term Stat<number>, by instances of their template. An instance is
+// a component named after its type arguments, Stat_number, and is added after
+// its template. `component Health = Stat<number>;` declares an instance under
+// its own name, so it is registered separately from Stat_number. Instances
+// extend the base of their template with the type arguments substituted, and
+// a generic base such as `extends Stat<number>` is instantiated the same way.
+func (p *Parser) resolveGenerics(program *ast.Program) error {
+	templates := make(map[string]*ast.Component)
+	declared := make(map[string]*ast.Component)
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok {
+			declared[comp.Name] = comp
+			if comp.IsGeneric() {
+				templates[comp.Name] = comp
+			}
+		}
+	}
+
+	added := make(map[*ast.Component][]ast.Node)
+	var fill func(comp *ast.Component, depth int) error
+	var resolveFields func(fields []*ast.Field, depth int) error
+
+	instantiate := func(t string, depth int) (string, error) {
+		name := instanceName(t)
+		if existing, ok := declared[name]; ok {
+			if existing.Instance != t {
+				return "", fmt.Errorf("component %s collides with the instance of %s", name, t)
+			}
+			return name, nil
+		}
+		comp := &ast.Component{Name: name, Instance: t}
+		declared[name] = comp
+		if err := fill(comp, depth+1); err != nil {
+			return "", err
+		}
+		template := templates[splitTypeArgs(t)[0]]
+		added[template] = append(added[template], comp)
+		return name, nil
+	}
+
+	// resolveBase replaces a generic base, such as Stat<number>, by its
+	// instance so resolveExtends copies the instance's fields
+	resolveBase := func(comp *ast.Component, depth int) error {
+		if !strings.Contains(comp.Extends, "<") {
+			return nil
+		}
+		name, err := instantiate(comp.Extends, depth)
+		if err != nil {
+			return fmt.Errorf("extends %s: %v", comp.Extends, err)
+		}
+		comp.Extends = name
+		return nil
+	}
+
+	// fill builds the fields of an instance from its template
+	fill = func(comp *ast.Component, depth int) error {
+		parts := splitTypeArgs(comp.Instance)
+		if depth > maxGenericDepth {
+			return fmt.Errorf("instances of %s nest more than %d deep", parts[0], maxGenericDepth)
+		}
+		template, ok := templates[parts[0]]
+		if !ok {
+			return fmt.Errorf("unknown generic component %s", parts[0])
+		}
+		args := parts[1:]
+		if len(args) != len(template.TypeParams) {
+			return fmt.Errorf("%s expects %d type argument(s), got %d", template.Name, len(template.TypeParams), len(args))
+		}
+		bindings := make(map[string]string, len(args))
+		for i, param := range template.TypeParams {
+			bindings[param] = args[i]
+		}
+
+	attributes:
+		for _, attr := range template.Attributes {
+			for _, own := range comp.Attributes {
+				if own == attr {
+					continue attributes
+				}
+			}
+			comp.Attributes = append(comp.Attributes, attr)
+		}
+		if template.Extends != "" {
+			comp.Extends = substituteType(template.Extends, bindings)
+			if err := resolveBase(comp, depth); err != nil {
+				return err
+			}
+		}
+		for _, field := range template.Fields {
+			instance := *field
+			instance.Type = substituteType(field.Type, bindings)
+			instance.MapKeyType = substituteType(field.MapKeyType, bindings)
+			instance.MapValueType = substituteType(field.MapValueType, bindings)
+			comp.Fields = append(comp.Fields, &instance)
+		}
+		return resolveFields(comp.Fields, depth)
+	}
+
+	resolveFields = func(fields []*ast.Field, depth int) error {
+		for _, field := range fields {
+			for _, t := range []*string{&field.Type, &field.MapValueType} {
+				if !strings.Contains(*t, "<") {
+					continue
+				}
+				name, err := instantiate(*t, depth)
+				if err != nil && depth == 0 {
+					return fmt.Errorf("field '%s': %v", field.Name, err)
+				}
+				if err != nil {
+					return err // Named after the field that started the instantiation
+				}
+				*t = name
+			}
+		}
+		return nil
+	}
+
+	for _, stmt := range program.Statements {
+		switch n := stmt.(type) {
+		case *ast.Component:
+			if n.IsGeneric() {
+				continue // Only instances are checked, once their types are known
+			}
+			var err error
+			if n.Instance != "" {
+				err = fill(n, 0)
+			} else if err = resolveBase(n, 0); err == nil {
+				err = resolveFields(n.Fields, 0)
+			}
+			if err != nil {
+				return p.newErrorf(n.Line, n.Column, "component %s: %v", n.Name, err)
+			}
+		case *ast.Event:
+			if err := resolveFields(n.Fields, 0); err != nil {
+				return p.newErrorf(n.Line, n.Column, "event %s: %v", n.Name, err)
+			}
+		case *ast.Resource:
+			if err := resolveFields(n.Fields, 0); err != nil {
+				return p.newErrorf(n.Line, n.Column, "resource %s: %v", n.Name, err)
+			}
+		case *ast.System:
+			if n.Query == nil {
+				continue
+			}
+			for _, names := range [][]string{n.Query.Components, n.Query.Mutable} {
+				for i, t := range names {
+					if !strings.Contains(t, "<") {
+						continue
+					}
+					name, err := instantiate(t, 0)
+					if err != nil {
+						return p.newErrorf(n.Line, n.Column, "system %s: %v", n.Name, err)
+					}
+					names[i] = name
+				}
+			}
+		}
+	}
+
+	if len(added) == 0 {
+		return nil
+	}
+	statements := make([]ast.Node, 0, len(program.Statements))
+	for _, stmt := range program.Statements {
+		statements = append(statements, stmt)
+		if comp, ok := stmt.(*ast.Component); ok {
+			statements = append(statements, added[comp]...)
+		}
+	}
+	program.Statements = statements
+	return nil
+}
+
+// instanceName returns the component name of a generic instance:
+// Pair<number, Enum.Material> is Pair_number_Enum_Material
+func instanceName(t string) string {
+	return strings.NewReplacer("<", "_", ", ", "_", ">", "", ".", "_").Replace(t)
+}
+
+// splitTypeArgs splits Pair<number, Stat<string>> into Pair and its type
+// arguments, number and Stat<string>
+func splitTypeArgs(t string) []string {
+	open := strings.Index(t, "<")
+	if open < 0 {
+		return []string{t}
+	}
+	parts := []string{t[:open]}
+	depth, start := 0, open+1
+	for i := open + 1; i < len(t)-1; i++ {
+		switch t[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(t[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, strings.TrimSpace(t[start:len(t)-1]))
+}
+
+// substituteType replaces the type parameters in t by their bindings
+func substituteType(t string, bindings map[string]string) string {
+	parts := splitTypeArgs(t)
+	if len(parts) == 1 {
+		if bound, ok := bindings[t]; ok {
+			return bound
+		}
+		return t
+	}
+	args := make([]string, len(parts)-1)
+	for i, arg := range parts[1:] {
+		args[i] = substituteType(arg, bindings)
+	}
+	return parts[0] + "<" + strings.Join(args, ", ") + ">"
+}
+
+// resolveExtends copies the fields of each component's base in front of its
+// own, so later stages see flattened components. Bases may be declared after
+// the components that extend them. Generic templates are left as written;
+// their instances carry the substituted base.
+func (p *Parser) resolveExtends(program *ast.Program) error {
+	components := make(map[string]*ast.Component)
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok {
+			components[comp.Name] = comp
+		}
+	}
+
+	resolved := make(map[string]bool)
+	var resolve func(comp *ast.Component, path []string) error
+	resolve = func(comp *ast.Component, path []string) error {
+		if resolved[comp.Name] || comp.Extends == "" {
+			resolved[comp.Name] = true
+			return nil
+		}
+		base, ok := components[comp.Extends]
+		if !ok {
+			return p.newErrorf(comp.Line, comp.Column, "component %s extends unknown component %s", comp.Name, comp.Extends)
+		}
+		path = append(path, comp.Name)
+		for i, name := range path {
+			if name == base.Name {
+				return p.newErrorf(comp.Line, comp.Column, "component inheritance cycle: %s -> %s", strings.Join(path[i:], " -> "), base.Name)
+			}
+		}
+		if err := resolve(base, path); err != nil {
+			return err
+		}
+
+		fields := make([]*ast.Field, 0, len(base.Fields)+len(comp.Fields))
+		for _, field := range base.Fields {
+			for _, own := range comp.Fields {
+				if own.Name == field.Name {
+					return p.newErrorf(comp.Line, comp.Column, "component %s: field '%s' collides with a field of %s", comp.Name, own.Name, base.Name)
+				}
+			}
+			inherited := *field
+			fields = append(fields, &inherited)
+		}
+		comp.Inherited = len(base.Fields)
+		comp.Fields = append(fields, comp.Fields...)
+		resolved[comp.Name] = true
+		return nil
+	}
+
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok && !comp.IsGeneric() {
+			if err := resolve(comp, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseAttributedDeclaration parses attributes like @replicated followed by the
+// component or relationship they apply to. A relationship takes exactly one
+// attribute, its type.
+func (p *Parser) parseAttributedDeclaration() (ast.Node, error) {
+	var attrs []*ast.Attribute
+	for p.curTokenIs(token.AT) {
+		if !p.peekTokenIs(token.IDENT) {
+			return nil, p.newError("expected identifier after @, got %s", p.peekToken.Type)
+		}
+		attr, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+	}
+
+	switch p.curToken.Type {
+	case token.COMPONENT:
+		comp, err := p.parseComponent()
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range attrs {
+			if attr.Name == "version" {
+				version, err := p.parseVersion(attr)
+				if err != nil {
+					return nil, err
+				}
+				comp.Version = version
+				continue
+			}
+			if len(attr.Arguments) > 0 {
+				return nil, p.newError("component attribute @%s does not take arguments", attr.Name)
+			}
+			comp.Attributes = append(comp.Attributes, attr.Name)
+		}
+		if err := p.checkMigrations(comp); err != nil {
+			return nil, err
+		}
+		return comp, nil
+	case token.RELATIONSHIP:
+		if len(attrs) != 1 || len(attrs[0].Arguments) > 0 {
+			return nil, p.newError("relationship expects a single @type attribute")
+		}
+		rel, err := p.parseRelationship()
+		if err != nil {
+			return nil, err
+		}
+		rel.Type = attrs[0].Name
+		return rel, nil
+	default:
+		return nil, p.newError("expected component or relationship after attributes, got %s", p.curToken.Type)
+	}
+}
+
 func (p *Parser) parseComponent() (*ast.Component, error) {
 	comp := &ast.Component{}
 
@@ -170,18 +552,84 @@ func (p *Parser) parseComponent() (*ast.Component, error) {
 		return nil, p.newError("expected component name, got %s", p.curToken.Type)
 	}
 	comp.Name = p.curToken.Literal
+	comp.Line, comp.Column = p.curToken.Line, p.curToken.Column
 
 	// Skip name
 	p.nextToken()
 
+	if p.curTokenIs(token.LT) {
+		for {
+			if !p.expectPeek(token.IDENT) {
+				return nil, p.newError("expected type parameter of %s, got %s", comp.Name, p.peekToken.Type)
+			}
+			for _, param := range comp.TypeParams {
+				if param == p.curToken.Literal {
+					return nil, p.newError("duplicate type parameter %s of %s", param, comp.Name)
+				}
+			}
+			comp.TypeParams = append(comp.TypeParams, p.curToken.Literal)
+			p.nextToken()
+			if !p.curTokenIs(token.COMMA) {
+				break
+			}
+		}
+		if !p.curTokenIs(token.GT) {
+			return nil, p.newError("expected ',' or '>' after type parameters of %s, got %s", comp.Name, p.curToken.Type)
+		}
+		p.nextToken()
+	} else if p.curTokenIs(token.ASSIGN) {
+		// component Health = Stat<number>; leaves curToken on the semicolon
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected generic instance after '=', got %s", p.peekToken.Type)
+		}
+		instance, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.Contains(instance, "<") {
+			return nil, p.newError("component %s = %s: expected a generic instance such as %s<number>", comp.Name, instance, instance)
+		}
+		comp.Instance = instance
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil, p.newError("expected ';' after component %s = %s, got %s", comp.Name, instance, p.peekToken.Type)
+		}
+		return comp, nil
+	}
+
+	if p.curTokenIs(token.IDENT) && p.curToken.Literal == "extends" {
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected component name after extends, got %s", p.peekToken.Type)
+		}
+		base, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
+		comp.Extends = base
+		p.nextToken()
+	}
+
 	// Expect '{'
 	if !p.curTokenIs(token.LBRACE) {
 		return nil, p.newError("expected '{', got %s", p.curToken.Type)
 	}
 	p.nextToken()
 
-	// Parse fields
+	// Parse fields and migrate blocks
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.IDENT) && p.curToken.Literal == "migrate" && p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "from" {
+			migration, err := p.parseMigration()
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range comp.Migrations {
+				if m.From == migration.From {
+					return nil, p.newError("duplicate migrate block from version %d in component %s", m.From, comp.Name)
+				}
+			}
+			comp.Migrations = append(comp.Migrations, migration)
+			continue
+		}
+
 		field, err := p.parseField()
 		if err != nil {
 			return nil, err
@@ -204,10 +652,17 @@ func (p *Parser) parseField() (*ast.Field, error) {
 	var defaultValueExpr ast.Expression
 	var err error
 
+	// Field attributes such as @transient or @range(0, 100) precede the type
+	for p.curTokenIs(token.AT) {
+		attr, err := p.parseAttribute()
+		if err != nil {
+			return nil, err
+		}
+		field.Attributes = append(field.Attributes, attr)
+	}
+
 	// Check if the type is 'table'
 	if p.curTokenIs(token.TABLE) {
-		fmt.Println("DEBUG: p.curTokenIs(token.TABLE) is TRUE") // DEBUG
-		os.Stdout.Sync()                                        // DEBUG
 		field.Type = "table"
 		p.nextToken() // Consume 'table'
 
@@ -234,7 +689,9 @@ func (p *Parser) parseField() (*ast.Field, error) {
 		if !p.curTokenIs(token.IDENT) {
 			return nil, p.newError("expected table value type (identifier), got %s", p.curToken.Type)
 		}
-		field.MapValueType = p.curToken.Literal
+		if field.MapValueType, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
 		p.nextToken() // Consume value type
 
 		// Expect >
@@ -252,7 +709,9 @@ func (p *Parser) parseField() (*ast.Field, error) {
 
 	} else if p.curTokenIs(token.IDENT) {
 		// --- Regular Type Parsing (Type name;) ---
-		field.Type = p.curToken.Literal
+		if field.Type, err = p.parseTypeName(); err != nil {
+			return nil, err
+		}
 
 		// Check for optional type
 		if p.peekTokenIs(token.QUESTION) {
@@ -283,20 +742,13 @@ func (p *Parser) parseField() (*ast.Field, error) {
 		}
 		field.DefaultValue = defaultValueExpr
 		// parseExpression leaves curToken on the last token of the expression.
-	}
-
-	// --- Find the semicolon ---
-	// After type/name and optional default value, skip until we find the semicolon.
-	// This simplifies handling the end of potentially complex default value expressions.
-	for !p.curTokenIs(token.SEMICOLON) && !p.curTokenIs(token.EOF) {
-		// Maybe add a check here to prevent infinite loops if semicolon is missing on the line?
 		p.nextToken()
 	}
 
+	// The field ends right after its name or default value, so anything else,
+	// such as an operator the expression parser doesn't know, is an error
 	if !p.curTokenIs(token.SEMICOLON) {
-		// If we hit EOF without finding semicolon, report error at the field name's position (approx)
-		// TODO: Improve error position reporting
-		return nil, p.newError("missing ';' after field definition for field '%s'", field.Name)
+		return nil, p.newError("expected ';' after field '%s', got %s", field.Name, p.curToken.Type)
 	}
 
 	p.nextToken() // Consume the SEMICOLON.
@@ -304,6 +756,187 @@ func (p *Parser) parseField() (*ast.Field, error) {
 	return field, nil
 }
 
+// parseTypeName parses a type name, which may be dotted as in Enum.Material or
+// instantiate a generic component as in Stat<number>. It leaves curToken on
+// the last identifier or '>'.
+func (p *Parser) parseTypeName() (string, error) {
+	name := p.curToken.Literal
+	for p.peekTokenIs(token.DOT) {
+		p.nextToken() // Consume '.'
+		if !p.expectPeek(token.IDENT) {
+			return "", p.newError("expected type name after '.', got %s", p.peekToken.Type)
+		}
+		name += "." + p.curToken.Literal
+	}
+	if !p.peekTokenIs(token.LT) {
+		return name, nil
+	}
+	p.nextToken() // Move to '<'
+
+	var args []string
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return "", p.newError("expected type argument of %s, got %s", name, p.peekToken.Type)
+		}
+		arg, err := p.parseTypeName()
+		if err != nil {
+			return "", err
+		}
+		args = append(args, arg)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken() // Move to ','
+	}
+	if !p.expectPeek(token.GT) {
+		return "", p.newError("expected ',' or '>' after type arguments of %s, got %s", name, p.peekToken.Type)
+	}
+	return name + "<" + strings.Join(args, ", ") + ">", nil
+}
+
+// checkMigrations verifies that every migrate block upgrades from a version
+// older than the component's @version
+func (p *Parser) checkMigrations(comp *ast.Component) error {
+	for _, m := range comp.Migrations {
+		if m.From >= comp.Version {
+			return p.newError("component %s: migrate from %d requires @version greater than %d", comp.Name, m.From, m.From)
+		}
+	}
+	return nil
+}
+
+// parseVersion returns the version number of a @version(n) attribute
+func (p *Parser) parseVersion(attr *ast.Attribute) (int, error) {
+	if len(attr.Arguments) == 1 {
+		if num, ok := attr.Arguments[0].(*ast.NumberLiteral); ok {
+			if version, err := strconv.Atoi(num.Value); err == nil && version > 0 {
+				return version, nil
+			}
+		}
+	}
+	return 0, p.newError("@version expects a single positive integer")
+}
+
+// parseMigration parses `migrate from N { ... }` where each step is one of
+// `rename old -> new;`, `default field = expr;` or `remove field;`. It leaves
+// curToken after the closing brace.
+func (p *Parser) parseMigration() (*ast.Migration, error) {
+	p.nextToken() // Consume 'migrate'
+	if !p.expectPeek(token.INT) {
+		return nil, p.newError("expected version number after 'migrate from', got %s", p.peekToken.Type)
+	}
+	from, err := strconv.Atoi(p.curToken.Literal)
+	if err != nil || from < 1 {
+		return nil, p.newError("invalid migration version %q", p.curToken.Literal)
+	}
+	migration := &ast.Migration{From: from}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil, p.newError("expected '{' after migrate from %d, got %s", from, p.peekToken.Type)
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if !p.curTokenIs(token.IDENT) {
+			return nil, p.newError("expected rename, default or remove, got %s", p.curToken.Type)
+		}
+		step := &ast.MigrationStep{Action: p.curToken.Literal}
+		if step.Action != ast.MigrateRename && step.Action != ast.MigrateDefault && step.Action != ast.MigrateRemove {
+			return nil, p.newError("unknown migration step '%s' (expected rename, default or remove)", step.Action)
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected field name after %s, got %s", step.Action, p.peekToken.Type)
+		}
+		step.Field = p.curToken.Literal
+
+		switch step.Action {
+		case ast.MigrateRename:
+			if !p.expectPeek(token.ARROW) {
+				return nil, p.newError("expected '->' in rename of '%s', got %s", step.Field, p.peekToken.Type)
+			}
+			if !p.expectPeek(token.IDENT) {
+				return nil, p.newError("expected new field name after '->', got %s", p.peekToken.Type)
+			}
+			step.To = p.curToken.Literal
+		case ast.MigrateDefault:
+			if !p.expectPeek(token.ASSIGN) {
+				return nil, p.newError("expected '=' after default %s, got %s", step.Field, p.peekToken.Type)
+			}
+			p.nextToken()
+			step.Value, err = p.parseExpression(LOWEST)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil, p.newError("expected ';' after migration step, got %s", p.peekToken.Type)
+		}
+		p.nextToken()
+		migration.Steps = append(migration.Steps, step)
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+		return nil, p.newError("expected '}' to close migrate block, got %s", p.curToken.Type)
+	}
+	p.nextToken()
+	return migration, nil
+}
+
+// parseConst parses `const NAME: type = expr;`, where the type is optional.
+// It leaves curToken on the semicolon.
+func (p *Parser) parseConst() (*ast.Const, error) {
+	if !p.expectPeek(token.IDENT) {
+		return nil, p.newError("expected constant name after 'const', got %s", p.peekToken.Type)
+	}
+	c := &ast.Const{Name: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COLON) {
+		p.nextToken() // Move to :
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected type after ':' in const %s, got %s", c.Name, p.peekToken.Type)
+		}
+		c.Type = p.curToken.Literal
+	}
+
+	if !p.expectPeek(token.ASSIGN) {
+		return nil, p.newError("expected '=' in const %s, got %s", c.Name, p.peekToken.Type)
+	}
+	p.nextToken() // Move to the value
+	value, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	c.Value = value
+
+	if !p.expectPeek(token.SEMICOLON) {
+		return nil, p.newError("expected ';' after const %s, got %s", c.Name, p.peekToken.Type)
+	}
+	return c, nil
+}
+
+// parseAttribute parses @name or @name(args...) and leaves curToken on the
+// token following the attribute
+func (p *Parser) parseAttribute() (*ast.Attribute, error) {
+	p.nextToken() // Consume '@'
+	if !p.curTokenIs(token.IDENT) {
+		return nil, p.newError("expected attribute name after '@', got %s", p.curToken.Type)
+	}
+	attr := &ast.Attribute{Name: p.curToken.Literal}
+
+	if p.peekTokenIs(token.LPAREN) {
+		p.nextToken() // Move to (
+		args, err := p.parseExpressionList(token.RPAREN)
+		if err != nil {
+			return nil, err
+		}
+		attr.Arguments = args
+	}
+	p.nextToken() // Consume name or )
+
+	return attr, nil
+}
+
 func (p *Parser) parseRelationship() (*ast.Relationship, error) {
 	rel := &ast.Relationship{}
 
@@ -393,8 +1026,19 @@ func (p *Parser) parseQueryContent() (*ast.Query, error) {
 				}
 				query.Relations = append(query.Relations, rel)
 			} else {
-				// Regular component name
-				query.Components = append(query.Components, p.curToken.Literal)
+				// Regular component name, optionally marked mut
+				mutable := p.curToken.Literal == "mut" && p.peekTokenIs(token.IDENT)
+				if mutable {
+					p.nextToken() // Consume mut
+				}
+				name, err := p.parseTypeName()
+				if err != nil {
+					return nil, err
+				}
+				if mutable {
+					query.Mutable = append(query.Mutable, name)
+				}
+				query.Components = append(query.Components, name)
 				p.nextToken() // Consume component name
 			}
 		} else {
@@ -487,8 +1131,21 @@ func (p *Parser) parseSystem() (*ast.System, error) {
 				return nil, p.newError("expected ')' to close query, got %s", p.curToken.Type)
 			}
 			p.nextToken() // Consume )
+			if p.curTokenIs(token.IDENT) && p.curToken.Literal == "where" {
+				p.nextToken() // Consume 'where'
+				if p.curTokenIs(token.LBRACE) {
+					return nil, p.newError("expected a condition after 'where', got %s", p.curToken.Type)
+				}
+				where, err := p.parseExpression(LOWEST)
+				if err != nil {
+					return nil, err
+				}
+				system.Query.Where = where
+				p.nextToken() // Consume the last token of the condition
+			}
 		case token.IDENT:
-			if p.curToken.Literal == "params" {
+			switch p.curToken.Literal {
+			case "params":
 				if system.Parameters != nil {
 					return nil, p.newError("duplicate params block")
 				}
@@ -497,7 +1154,73 @@ func (p *Parser) parseSystem() (*ast.System, error) {
 					return nil, err
 				}
 				system.Parameters = params
-			} else {
+			case "phase":
+				if system.Phase != "" {
+					return nil, p.newError("duplicate phase definition")
+				}
+				names, err := p.parseSystemNames()
+				if err != nil {
+					return nil, err
+				}
+				if len(names) != 1 {
+					return nil, p.newError("phase expects a single name, got %d", len(names))
+				}
+				system.Phase = names[0]
+			case "after":
+				if system.After != nil {
+					return nil, p.newError("duplicate after definition")
+				}
+				names, err := p.parseSystemNames()
+				if err != nil {
+					return nil, err
+				}
+				system.After = names
+			case "before":
+				if system.Before != nil {
+					return nil, p.newError("duplicate before definition")
+				}
+				names, err := p.parseSystemNames()
+				if err != nil {
+					return nil, err
+				}
+				system.Before = names
+			case "reads":
+				if system.Reads != nil {
+					return nil, p.newError("duplicate reads definition")
+				}
+				names, err := p.parseComponentList()
+				if err != nil {
+					return nil, err
+				}
+				system.Reads = names
+			case "writes":
+				if system.Writes != nil {
+					return nil, p.newError("duplicate writes definition")
+				}
+				names, err := p.parseComponentList()
+				if err != nil {
+					return nil, err
+				}
+				system.Writes = names
+			case "emits":
+				if system.Emits != nil {
+					return nil, p.newError("duplicate emits definition")
+				}
+				names, err := p.parseComponentList()
+				if err != nil {
+					return nil, err
+				}
+				system.Emits = names
+			case "uses":
+				if system.Uses != nil {
+					return nil, p.newError("duplicate uses definition")
+				}
+				names, err := p.parseComponentList()
+				if err != nil {
+					return nil, err
+				}
+				system.Uses = names
+			default:
 				return nil, p.newError("unexpected identifier '%s' in system body", p.curToken.Literal)
 			}
 		case token.FREQUENCY:
@@ -561,6 +1284,255 @@ func (p *Parser) parseSystem() (*ast.System, error) {
 	return system, nil
 }
 
+// parseSystemNames parses the comma separated names of an ordering clause
+// such as `after: Physics, Input`. It leaves curToken after the last name.
+func (p *Parser) parseSystemNames() ([]string, error) {
+	keyword := p.curToken.Literal
+	if !p.expectPeek(token.COLON) {
+		return nil, p.newError("expected ':' after %s, got %s", keyword, p.peekToken.Type)
+	}
+	var names []string
+	for {
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected name in %s, got %s", keyword, p.peekToken.Type)
+		}
+		names = append(names, p.curToken.Literal)
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken() // Consume the name, leaving ',' current
+	}
+	p.nextToken() // Consume the last name
+	return names, nil
+}
+
+// parseComponentList parses an access clause such as `writes(Transform)`, or
+// `reads Damage` without parentheses. It leaves curToken after the clause.
+func (p *Parser) parseComponentList() ([]string, error) {
+	keyword := p.curToken.Literal
+	if p.peekTokenIs(token.IDENT) {
+		var names []string
+		for {
+			p.nextToken() // Consume the keyword or ','
+			if !p.curTokenIs(token.IDENT) {
+				return nil, p.newError("expected name in %s, got %s", keyword, p.curToken.Type)
+			}
+			names = append(names, p.curToken.Literal)
+			p.nextToken() // Consume the name
+			if !p.curTokenIs(token.COMMA) {
+				return names, nil
+			}
+		}
+	}
+	if !p.expectPeek(token.LPAREN) {
+		return nil, p.newError("expected '(' or a name after %s, got %s", keyword, p.peekToken.Type)
+	}
+	names := []string{}
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected component name in %s, got %s", keyword, p.peekToken.Type)
+		}
+		names = append(names, p.curToken.Literal)
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil, p.newError("expected ',' or ')' in %s, got %s", keyword, p.peekToken.Type)
+		}
+	}
+	p.nextToken() // Consume the last name or '('
+	p.nextToken() // Consume )
+	return names, nil
+}
+
+// parseEvent parses `event Name { fields }`. The closing brace is consumed by
+// ParseProgram.
+func (p *Parser) parseEvent() (*ast.Event, error) {
+	event := &ast.Event{Line: p.peekToken.Line, Column: p.peekToken.Column}
+	var err error
+	event.Name, event.Fields, err = p.parseFieldBlock("event")
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// parseResource parses `resource Name { fields }`. The closing brace is
+// consumed by ParseProgram.
+func (p *Parser) parseResource() (*ast.Resource, error) {
+	resource := &ast.Resource{Line: p.peekToken.Line, Column: p.peekToken.Column}
+	var err error
+	resource.Name, resource.Fields, err = p.parseFieldBlock("resource")
+	if err != nil {
+		return nil, err
+	}
+	return resource, nil
+}
+
+// parsePrefab parses `prefab Name { Component; Component { field = expr, ... }; }`,
+// where the name may be followed by `extends Base`. The closing brace is
+// consumed by ParseProgram.
+func (p *Parser) parsePrefab() (*ast.Prefab, error) {
+	prefab := &ast.Prefab{Line: p.peekToken.Line, Column: p.peekToken.Column}
+	if !p.expectPeek(token.IDENT) {
+		return nil, p.newError("expected prefab name, got %s", p.peekToken.Type)
+	}
+	prefab.Name = p.curToken.Literal
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "extends" {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected prefab name after extends, got %s", p.peekToken.Type)
+		}
+		prefab.Extends = p.curToken.Literal
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil, p.newError("expected '{' after prefab name, got %s", p.peekToken.Type)
+	}
+	p.nextToken()
+
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		if !p.curTokenIs(token.IDENT) {
+			return nil, p.newError("expected component name in prefab %s, got %s", prefab.Name, p.curToken.Type)
+		}
+		comp := &ast.PrefabComponent{Name: p.curToken.Literal}
+		if p.peekTokenIs(token.LBRACE) {
+			p.nextToken()
+			overrides, err := p.parsePrefabOverrides()
+			if err != nil {
+				return nil, err
+			}
+			comp.Overrides = overrides
+		}
+		if !p.expectPeek(token.SEMICOLON) {
+			return nil, p.newError("expected ';' after prefab component %s, got %s", comp.Name, p.peekToken.Type)
+		}
+		p.nextToken()
+		prefab.Components = append(prefab.Components, comp)
+	}
+
+	if !p.curTokenIs(token.RBRACE) {
+		return nil, p.newError("expected '}' to close prefab %s, got %s", prefab.Name, p.curToken.Type)
+	}
+	return prefab, nil
+}
+
+// parsePrefabOverrides parses `{ field = expr, ... }`, allowing a trailing
+// comma, and leaves curToken on the closing brace
+func (p *Parser) parsePrefabOverrides() ([]*ast.PrefabOverride, error) {
+	var overrides []*ast.PrefabOverride
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil, p.newError("expected field name in override, got %s", p.peekToken.Type)
+		}
+		override := &ast.PrefabOverride{Field: p.curToken.Literal}
+		if !p.expectPeek(token.ASSIGN) {
+			return nil, p.newError("expected '=' after override of '%s', got %s", override.Field, p.peekToken.Type)
+		}
+		p.nextToken()
+		value, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		override.Value = value
+		overrides = append(overrides, override)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil, p.newError("expected ',' or '}' in overrides, got %s", p.peekToken.Type)
+	}
+	return overrides, nil
+}
+
+// parseObserver parses `observer Name { on changed(Component) where expr { code } }`,
+// where the where clause is optional. The closing brace is consumed by
+// ParseProgram.
+func (p *Parser) parseObserver() (*ast.Observer, error) {
+	observer := &ast.Observer{Line: p.peekToken.Line, Column: p.peekToken.Column}
+	if !p.expectPeek(token.IDENT) {
+		return nil, p.newError("expected observer name, got %s", p.peekToken.Type)
+	}
+	observer.Name = p.curToken.Literal
+	if !p.expectPeek(token.LBRACE) {
+		return nil, p.newError("expected '{' after observer name, got %s", p.peekToken.Type)
+	}
+	if !p.peekTokenIs(token.IDENT) || p.peekToken.Literal != "on" {
+		return nil, p.newError("expected 'on' in observer %s, got %s", observer.Name, p.peekToken.Literal)
+	}
+	p.nextToken()
+
+	if !p.expectPeek(token.IDENT) {
+		return nil, p.newError("expected added, changed or removed after 'on', got %s", p.peekToken.Type)
+	}
+	observer.Trigger = p.curToken.Literal
+	switch observer.Trigger {
+	case "added", "changed", "removed":
+	default:
+		return nil, p.newError("expected added, changed or removed after 'on', got %s", observer.Trigger)
+	}
+	if !p.expectPeek(token.LPAREN) {
+		return nil, p.newError("expected '(' after %s, got %s", observer.Trigger, p.peekToken.Type)
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil, p.newError("expected component name in %s, got %s", observer.Trigger, p.peekToken.Type)
+	}
+	observer.Component = p.curToken.Literal
+	if !p.expectPeek(token.RPAREN) {
+		return nil, p.newError("expected ')' after %s, got %s", observer.Component, p.peekToken.Type)
+	}
+
+	if p.peekTokenIs(token.IDENT) && p.peekToken.Literal == "where" {
+		p.nextToken()
+		p.nextToken()
+		where, err := p.parseExpression(LOWEST)
+		if err != nil {
+			return nil, err
+		}
+		observer.Where = where
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil, p.newError("expected '{' to start the code of observer %s, got %s", observer.Name, p.peekToken.Type)
+	}
+	p.nextToken()
+	observer.Code = p.parseCodeBlock()
+	if !p.curTokenIs(token.RBRACE) {
+		return nil, p.newError("expected '}' to close code block, got %s", p.curToken.Type)
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil, p.newError("expected '}' to close observer %s, got %s", observer.Name, p.peekToken.Type)
+	}
+	return observer, nil
+}
+
+// parseFieldBlock parses the name and fields of a declaration such as
+// `event Name { fields }`, leaving curToken on the closing brace
+func (p *Parser) parseFieldBlock(kind string) (string, []*ast.Field, error) {
+	if !p.expectPeek(token.IDENT) {
+		return "", nil, p.newError("expected %s name, got %s", kind, p.peekToken.Type)
+	}
+	name := p.curToken.Literal
+	if !p.expectPeek(token.LBRACE) {
+		return "", nil, p.newError("expected '{' after %s name, got %s", kind, p.peekToken.Type)
+	}
+	p.nextToken() // Consume {
+
+	var fields []*ast.Field
+	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		field, err := p.parseField()
+		if err != nil {
+			return "", nil, err
+		}
+		if field != nil {
+			fields = append(fields, field)
+		}
+	}
+	if !p.curTokenIs(token.RBRACE) {
+		return "", nil, p.newError("expected '}' to close %s, got %s", kind, p.curToken.Type)
+	}
+	return name, fields, nil
+}
+
 func (p *Parser) parseParametersBlock() ([]*ast.Parameter, error) {
 	params := []*ast.Parameter{}
 	p.nextToken() // Consume 'params' identifier
@@ -575,7 +1547,10 @@ func (p *Parser) parseParametersBlock() ([]*ast.Parameter, error) {
 		if !p.curTokenIs(token.IDENT) {
 			return nil, p.newError("expected parameter type, got %s", p.curToken.Type)
 		}
-		paramType := p.curToken.Literal
+		paramType, err := p.parseTypeName()
+		if err != nil {
+			return nil, err
+		}
 		p.nextToken()
 
 		if !p.curTokenIs(token.IDENT) {
@@ -671,7 +1646,8 @@ func isWordToken(tok token.Token) bool {
 	case token.IDENT, token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE,
 		token.COMPONENT, token.SYSTEM, token.RELATIONSHIP, token.QUERY, // Removed PARAMS
 		token.FREQUENCY, token.PRIORITY, token.RETURN, token.FUNCTION, // More keywords if needed
-		token.IF, token.ELSE, token.FOR, token.WHILE: // Removed DO, END, LOCAL
+		token.IF, token.ELSE, token.FOR, token.WHILE, // Removed DO, END, LOCAL
+		token.CONST, token.NULL:
 		return true
 	default:
 		return false
@@ -776,6 +1752,10 @@ func (p *Parser) parseBooleanLiteral() (ast.Expression, error) {
 	return &ast.BooleanLiteral{Value: p.curTokenIs(token.TRUE)}, nil
 }
 
+func (p *Parser) parseNilLiteral() (ast.Expression, error) {
+	return &ast.NilLiteral{}, nil
+}
+
 func (p *Parser) parseGroupedExpression() (ast.Expression, error) {
 	p.nextToken() // Consume '('
 	exp, err := p.parseExpression(LOWEST)
@@ -794,8 +1774,7 @@ func (p *Parser) parseTableConstructor() (ast.Expression, error) {
 
 	// Handle empty table {}
 	if p.peekTokenIs(token.RBRACE) {
-		p.nextToken() // Consume {
-		p.nextToken() // Consume }
+		p.nextToken() // Consume {, leaving curToken on }
 		return table, nil
 	}
 
@@ -828,7 +1807,7 @@ func (p *Parser) parseTableConstructor() (ast.Expression, error) {
 	if !p.curTokenIs(token.RBRACE) {
 		return nil, p.newErrorf(startLine, startCol, "expected '}' or ',' in table constructor, got %s", p.curToken.Type)
 	}
-	p.nextToken() // Consume }
+	// Like other expressions, the table leaves curToken on its last token, }
 
 	return table, nil
 }
@@ -852,6 +1831,7 @@ func (p *Parser) parseTableField() (ast.Expression, ast.Expression, error) {
 		if !p.expectPeek(token.ASSIGN) {
 			return nil, nil, p.newError("expected '=' after table key expression")
 		}
+		p.nextToken() // Consume =
 		value, err = p.parseExpression(LOWEST)
 		if err != nil {
 			return nil, nil, err
@@ -947,6 +1927,55 @@ func (p *Parser) parseMemberAccessExpression(left ast.Expression) (ast.Expressio
 	return exp, nil
 }
 
+// Parses binary operators like a + b or a && b
+func (p *Parser) parseInfixExpression(left ast.Expression) (ast.Expression, error) {
+	expression := &ast.InfixExpression{
+		Left:     left,
+		Operator: p.curToken.Literal,
+	}
+	precedence := p.curPrecedence()
+	if p.curTokenIs(token.CONCAT) {
+		precedence-- // a .. b .. c groups as a .. (b .. c)
+	}
+	p.nextToken() // Consume the operator
+	var err error
+	expression.Right, err = p.parseExpression(precedence)
+	if err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+// Parses indexing like list[1] or Enum.KeyCode["W"]
+func (p *Parser) parseIndexExpression(left ast.Expression) (ast.Expression, error) {
+	p.nextToken() // Consume '['
+	index, err := p.parseExpression(LOWEST)
+	if err != nil {
+		return nil, err
+	}
+	if !p.expectPeek(token.RBRACKET) { // Consume ']'
+		return nil, p.newError("expected ']' after index expression")
+	}
+	return &ast.IndexExpression{Left: left, Index: index}, nil
+}
+
+// Parses method calls like a:Lerp(b, t). Leaves curToken on the closing ')'.
+func (p *Parser) parseMethodCallExpression(object ast.Expression) (ast.Expression, error) {
+	if !p.expectPeek(token.IDENT) {
+		return nil, p.newError("expected method name after ':', got %s", p.peekToken.Type)
+	}
+	call := &ast.MethodCallExpression{Object: object, Method: &ast.Identifier{Value: p.curToken.Literal}}
+	if !p.expectPeek(token.LPAREN) {
+		return nil, p.newError("expected '(' after method name %s, got %s", call.Method.Value, p.peekToken.Type)
+	}
+	var err error
+	call.Arguments, err = p.parseExpressionList(token.RPAREN)
+	if err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
 // Parsing function for prefix operators like - or !
 func (p *Parser) parsePrefixExpression() (ast.Expression, error) {
 	expression := &ast.PrefixExpression{
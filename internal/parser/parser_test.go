@@ -231,6 +231,35 @@ func TestParseField_TableType(t *testing.T) {
 }
 
 // Add Test for Default Value Expression Parsing
+func TestParseField_EnumType(t *testing.T) {
+	input := `component Surface {
+		Enum.Material material = Enum.Material.Plastic;
+		Enum.KeyCode? key;
+		table<string, Enum.KeyCode> binds;
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	fields := program.Statements[0].(*ast.Component).Fields
+	if fields[0].Type != "Enum.Material" || fields[0].Name != "material" || fields[0].DefaultValue.String() != "Enum.Material.Plastic" {
+		t.Errorf("material field wrong. got=%s %s = %v", fields[0].Type, fields[0].Name, fields[0].DefaultValue)
+	}
+	if fields[1].Type != "Enum.KeyCode" || !fields[1].Optional || fields[1].Name != "key" {
+		t.Errorf("key field wrong. got=%s optional=%v %s", fields[1].Type, fields[1].Optional, fields[1].Name)
+	}
+	if fields[2].MapValueType != "Enum.KeyCode" || fields[2].Name != "binds" {
+		t.Errorf("binds field wrong. got=table<%s, %s> %s", fields[2].MapKeyType, fields[2].MapValueType, fields[2].Name)
+	}
+
+	if _, err := New(`component C { Enum. material; }`).ParseProgram(); err == nil {
+		t.Errorf("expected error for incomplete dotted type")
+	}
+}
+
 func TestParseField_DefaultValueExpr(t *testing.T) {
 	input := `CFrame camera = CFrame.new(0, 1, -5);`
 	compInput := fmt.Sprintf("component Test { %s }", input)
@@ -256,3 +285,633 @@ func TestParseField_DefaultValueExpr(t *testing.T) {
 		t.Errorf("DefaultValue String() wrong.\nexpected=%q\ngot=%q", expected, got)
 	}
 }
+
+func TestParseField_InfixExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"16 * 1.5", "(16 * 1.5)"},
+		{"1 + 2 * 3", "(1 + (2 * 3))"},
+		{"(1 + 2) * 3", "((1 + 2) * 3)"},
+		{"10 - 4 - 3", "((10 - 4) - 3)"},
+		{"-a * b", "((-a) * b)"},
+		{"a < b == c >= d", "((a < b) == (c >= d))"},
+		{"a || b && !c", "(a || (b && (!c)))"},
+		{"x != y && y <= z", "((x != y) && (y <= z))"},
+		{`"a" .. "b" .. "c"`, `("a" .. ("b" .. "c"))`},
+		{`"n" .. 1 + 2`, `("n" .. (1 + 2))`},
+		{"Vector3.new(0, -9.81 * 2, 0)", "Vector3.new(0, ((-9.81) * 2), 0)"},
+		{"Vector3.new(1, 2, 3) * 2", "(Vector3.new(1, 2, 3) * 2)"},
+		{`Enum.KeyCode["W"]`, `Enum.KeyCode["W"]`},
+		{"list[i + 1] * 2", "(list[(i + 1)] * 2)"},
+		{"grid[1][2]", "grid[1][2]"},
+		{"a:Lerp(b, 0.5)", "a:Lerp(b, 0.5)"},
+		{"Color3.new(1, 0, 0):Lerp(c, t)", "Color3.new(1, 0, 0):Lerp(c, t)"},
+		{"-cf:Inverse().Position", "(-cf:Inverse().Position)"},
+		{"items:GetChildren()[1]", "items:GetChildren()[1]"},
+		{`{ ["a"] = 1, b = 2 }`, `{"a" = 1, b = 2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			p := New(fmt.Sprintf("component Test { any value = %s; }", tt.input))
+			program, err := p.ParseProgram()
+			if err != nil {
+				t.Fatalf("ParseProgram error: %v", err)
+			}
+			checkParserErrors(t, p)
+
+			field := program.Statements[0].(*ast.Component).Fields[0]
+			if got := field.DefaultValue.String(); got != tt.expected {
+				t.Errorf("DefaultValue String() wrong.\nexpected=%q\ngot=%q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParseField_TrailingTokens(t *testing.T) {
+	// Tokens the default value expression doesn't take must not be skipped
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"number q = 2 ^ 3;", "line 1, column 29: expected ';' after field 'q', got ILLEGAL"},
+		{"number q = 2 3;", "line 1, column 29: expected ';' after field 'q', got INT"},
+		{"number q extra;", "line 1, column 25: expected ';' after field 'q', got IDENT"},
+		{"table<string, number> t = {} {};", "line 1, column 45: expected ';' after field 't', got {"},
+		{"number q = 2", "line 1, column 29: expected ';' after field 'q', got }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := New(fmt.Sprintf("component C { %s }", tt.input)).ParseProgram()
+			if assert.Error(t, err) {
+				assert.Equal(t, tt.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseField_Attributes(t *testing.T) {
+	input := `component Health {
+		@range(0, 100) number current = 100;
+		@transient @maxLength(16) string tag;
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	comp := program.Statements[0].(*ast.Component)
+	if len(comp.Fields) != 2 {
+		t.Fatalf("Expected 2 fields, got %d", len(comp.Fields))
+	}
+
+	current := comp.Fields[0]
+	if len(current.Attributes) != 1 || current.Attributes[0].String() != "@range(0, 100)" {
+		t.Errorf("current attributes wrong. got=%v", current.Attributes)
+	}
+	if current.Name != "current" || current.DefaultValue.String() != "100" {
+		t.Errorf("current field wrong. got=%s = %v", current.Name, current.DefaultValue)
+	}
+
+	tag := comp.Fields[1]
+	if len(tag.Attributes) != 2 || tag.Attribute("transient") == nil || tag.Attribute("maxLength") == nil {
+		t.Errorf("tag attributes wrong. got=%v", tag.Attributes)
+	}
+}
+
+func TestParser_ComponentAttributes(t *testing.T) {
+	input := `@replicated @persistent
+	component Player {
+		string name;
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	comp, ok := program.Statements[0].(*ast.Component)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.Component. got=%T", program.Statements[0])
+	}
+	if len(comp.Attributes) != 2 || comp.Attributes[0] != "replicated" || comp.Attributes[1] != "persistent" {
+		t.Errorf("component.Attributes wrong. got=%v", comp.Attributes)
+	}
+
+	// A relationship still takes exactly one type attribute
+	p = New(`@a @b relationship R { child: A parent: B }`)
+	if _, err := p.ParseProgram(); err == nil {
+		t.Errorf("expected error for relationship with two attributes")
+	}
+}
+
+func TestParser_ComponentMigrations(t *testing.T) {
+	input := `@version(3) @persistent
+	component Player {
+		string displayName;
+		number coins = 5;
+		migrate from 1 {
+			rename name -> displayName;
+		}
+		migrate from 2 {
+			default coins = 5;
+			remove legacyFlag;
+		}
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	comp := program.Statements[0].(*ast.Component)
+	if comp.Version != 3 {
+		t.Errorf("component.Version wrong. expected=3, got=%d", comp.Version)
+	}
+	if len(comp.Attributes) != 1 || comp.Attributes[0] != "persistent" {
+		t.Errorf("component.Attributes wrong. got=%v", comp.Attributes)
+	}
+	if len(comp.Fields) != 2 {
+		t.Fatalf("expected 2 fields, got %d", len(comp.Fields))
+	}
+	if len(comp.Migrations) != 2 {
+		t.Fatalf("expected 2 migrations, got %d", len(comp.Migrations))
+	}
+
+	rename := comp.Migrations[0].Steps[0]
+	if comp.Migrations[0].From != 1 || rename.Action != ast.MigrateRename || rename.Field != "name" || rename.To != "displayName" {
+		t.Errorf("rename step wrong. got=%s", comp.Migrations[0])
+	}
+	steps := comp.Migrations[1].Steps
+	if len(steps) != 2 || steps[0].Action != ast.MigrateDefault || steps[0].Value.String() != "5" || steps[1].Action != ast.MigrateRemove || steps[1].Field != "legacyFlag" {
+		t.Errorf("migrate from 2 wrong. got=%s", comp.Migrations[1])
+	}
+
+	errorTests := []struct {
+		name  string
+		input string
+	}{
+		{"migration without version", `component A { number x; migrate from 1 { remove y; } }`},
+		{"migration from current version", `@version(2) component A { migrate from 2 { remove y; } }`},
+		{"duplicate migration", `@version(3) component A { migrate from 1 { remove y; } migrate from 1 { remove z; } }`},
+		{"unknown step", `@version(2) component A { migrate from 1 { drop y; } }`},
+		{"invalid version", `@version("2") component A { number x; }`},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.input).ParseProgram(); err == nil {
+				t.Errorf("expected error")
+			}
+		})
+	}
+}
+
+func TestParser_ComponentExtends(t *testing.T) {
+	input := `component Boss extends Enemy { number phase = 1; }
+	component Enemy extends Actor { number aggro = 5; }
+	component Actor {
+		Instance model;
+		Instance? humanoid;
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	boss := program.Statements[0].(*ast.Component)
+	var names []string
+	for _, field := range boss.Fields {
+		names = append(names, field.Name)
+	}
+	assert.Equal(t, []string{"model", "humanoid", "aggro", "phase"}, names)
+	assert.Equal(t, 3, boss.Inherited)
+	assert.Equal(t, "component Boss extends Enemy {\n    phase: number = 1\n}", boss.String())
+	assert.Len(t, program.Statements[2].(*ast.Component).Fields, 2)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`component Enemy extends Actor { }`, "line 1, column 12: component Enemy extends unknown component Actor"},
+		{`component A extends B { } component B extends A { }`, "line 1, column 38: component inheritance cycle: A -> B -> A"},
+		{`component Actor { number hp; } component Enemy extends Actor { number hp; }`, "line 1, column 43: component Enemy: field 'hp' collides with a field of Actor"},
+		{`component Enemy extends { }`, "line 1, column 18: expected component name after extends, got {"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.input).ParseProgram()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ParseProgram(%q) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestParser_GenericComponents(t *testing.T) {
+	input := `component Stat<T> {
+		T base;
+		T current;
+	}
+	component Pair<A, B> {
+		A first;
+		table<string, Stat<B>> seconds;
+	}
+	component Health = Stat<number>;
+	component Player {
+		Stat<number> speed;
+		Pair<string, Enum.Material> floor;
+	}
+	system Regen {
+		query(mut Health, Stat<number>)
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	var names []string
+	byName := make(map[string]*ast.Component)
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok {
+			names = append(names, comp.Name)
+			byName[comp.Name] = comp
+		}
+	}
+	// Instances follow their template, in the order they are first used
+	assert.Equal(t, []string{"Stat", "Stat_number", "Stat_Enum_Material", "Pair", "Pair_string_Enum_Material", "Health", "Player"}, names)
+
+	assert.True(t, byName["Stat"].IsGeneric())
+	assert.Equal(t, "component Stat<T> {\n    base: T\n    current: T\n}", byName["Stat"].String())
+	assert.Equal(t, "component Health = Stat<number>;", byName["Health"].String())
+	assert.Equal(t, "number", byName["Health"].Fields[1].Type)
+	assert.Equal(t, "Stat<number>", byName["Stat_number"].Instance)
+	assert.Equal(t, "Stat_number", byName["Player"].Fields[0].Type)
+	assert.Equal(t, "Pair_string_Enum_Material", byName["Player"].Fields[1].Type)
+	pair := byName["Pair_string_Enum_Material"]
+	assert.Equal(t, "string", pair.Fields[0].Type)
+	assert.Equal(t, "Stat_Enum_Material", pair.Fields[1].MapValueType)
+	assert.Equal(t, "Enum.Material", byName["Stat_Enum_Material"].Fields[0].Type)
+
+	sys := program.Statements[len(program.Statements)-1].(*ast.System)
+	assert.Equal(t, []string{"Health", "Stat_number"}, sys.Query.Components)
+	assert.Equal(t, []string{"Health"}, sys.Query.Mutable)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`component Health = Stat<number>;`, "line 1, column 12: component Health: unknown generic component Stat"},
+		{`component Stat<T> { T base; } component Health = Stat<number, string>;`, "line 1, column 42: component Health: Stat expects 1 type argument(s), got 2"},
+		{`component Stat<T> { T base; } component Stat_number { number base; } component P { Stat<number> s; }`, "line 1, column 81: component P: field 's': component Stat_number collides with the instance of Stat<number>"},
+		{`component Box<T> { Box<Box<T>> inner; } component P { Box<number> b; }`, "line 1, column 52: component P: field 'b': instances of Box nest more than 16 deep"},
+		{`component Stat<T, T> { }`, "line 1, column 20: duplicate type parameter T of Stat"},
+		{`component Health = Stat;`, "line 1, column 21: component Health = Stat: expected a generic instance such as Stat<number>"},
+		{`system S { query(Stat<number>) }`, "line 1, column 9: system S: unknown generic component Stat"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.input).ParseProgram()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ParseProgram(%q) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestParser_GenericExtends(t *testing.T) {
+	input := `component Actor { number hp = 100; }
+	component Stat<T> extends Actor { T base; }
+	component Ranged<T> extends Stat<T> { T range; }
+	component Health = Stat<number>;
+	component Mana extends Stat<number> { number regen; }
+	component Player { Ranged<string> bow; }`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	byName := make(map[string]*ast.Component)
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok {
+			byName[comp.Name] = comp
+		}
+	}
+	fieldNames := func(comp *ast.Component) []string {
+		var names []string
+		for _, field := range comp.Fields {
+			names = append(names, field.Name+":"+field.Type)
+		}
+		return names
+	}
+
+	// Instances extend their template's base, with its type arguments
+	assert.Equal(t, "Actor", byName["Health"].Extends)
+	assert.Equal(t, []string{"hp:number", "base:number"}, fieldNames(byName["Health"]))
+	assert.Equal(t, 1, byName["Health"].Inherited)
+	assert.Equal(t, "Stat_string", byName["Ranged_string"].Extends)
+	assert.Equal(t, []string{"hp:number", "base:string", "range:string"}, fieldNames(byName["Ranged_string"]))
+
+	// A generic base is instantiated
+	assert.Equal(t, "Stat_number", byName["Mana"].Extends)
+	assert.Equal(t, []string{"hp:number", "base:number", "regen:number"}, fieldNames(byName["Mana"]))
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`component Mana extends Stat<number> { }`, "line 1, column 12: component Mana: extends Stat<number>: unknown generic component Stat"},
+		{`component Stat<T> extends Base<T> { } component Health = Stat<number>;`, "line 1, column 50: component Health: extends Base<number>: unknown generic component Base"},
+		{`component Stat<T> extends Actor { T hp; } component Actor { number hp; } component Health = Stat<number>;`, "line 1, column 85: component Health: field 'hp' collides with a field of Actor"},
+	}
+	for _, tt := range tests {
+		_, err := New(tt.input).ParseProgram()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ParseProgram(%q) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestParser_Const(t *testing.T) {
+	input := `const GRAVITY: number = 9.81;
+	const JUMP = GRAVITY * 2;
+	component Body {
+		number fall = GRAVITY;
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(program.Statements))
+	}
+	tests := []string{
+		"const GRAVITY: number = 9.81;",
+		"const JUMP = (GRAVITY * 2);",
+	}
+	for i, expected := range tests {
+		c, ok := program.Statements[i].(*ast.Const)
+		if !ok {
+			t.Fatalf("program.Statements[%d] is not *ast.Const. got=%T", i, program.Statements[i])
+		}
+		if c.String() != expected {
+			t.Errorf("const String() wrong. expected=%q, got=%q", expected, c.String())
+		}
+	}
+
+	if _, err := New(`const X: number 5;`).ParseProgram(); err == nil {
+		t.Errorf("expected error for const without '='")
+	}
+	if _, err := New(`const X = 5`).ParseProgram(); err == nil {
+		t.Errorf("expected error for const without ';'")
+	}
+}
+
+func TestParser_SystemOrdering(t *testing.T) {
+	input := `system Movement {
+		query(Position)
+		phase: PreSimulation
+		after: Input, Physics
+		before: Render
+		priority: 5
+		{
+			move()
+		}
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	sys := program.Statements[0].(*ast.System)
+	if sys.Phase != "PreSimulation" {
+		t.Errorf("system.Phase wrong. got=%q", sys.Phase)
+	}
+	assert.Equal(t, []string{"Input", "Physics"}, sys.After)
+	assert.Equal(t, []string{"Render"}, sys.Before)
+	if sys.Priority == nil || sys.Code == "" {
+		t.Errorf("clauses after the ordering constraints were not parsed")
+	}
+
+	invalid := []string{
+		`system S { after: }`,
+		`system S { after Input }`,
+		`system S { phase: A, B }`,
+		`system S { after: A after: B }`,
+	}
+	for _, input := range invalid {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParser_SystemAccess(t *testing.T) {
+	input := `system Move {
+		query(mut Transform, Velocity)
+		reads(Gravity)
+		writes(Stats, Trail)
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	sys := program.Statements[0].(*ast.System)
+	assert.Equal(t, []string{"Transform", "Velocity"}, sys.Query.Components)
+	assert.Equal(t, []string{"Transform"}, sys.Query.Mutable)
+	assert.Equal(t, []string{"Gravity"}, sys.Reads)
+	assert.Equal(t, []string{"Stats", "Trail"}, sys.Writes)
+
+	for _, input := range []string{`system S { writes(A B) }`, `system S { reads, A }`, `system S { reads(1) }`} {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParser_Event(t *testing.T) {
+	input := `event Damage {
+		Entity target;
+		number amount;
+	}
+	system Apply {
+		query(mut Health)
+		reads Damage, Heal
+		emits Death
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	event, ok := program.Statements[0].(*ast.Event)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.Event. got=%T", program.Statements[0])
+	}
+	assert.Equal(t, "event Damage { target: Entity; amount: number; }", event.String())
+
+	sys := program.Statements[1].(*ast.System)
+	assert.Equal(t, []string{"Damage", "Heal"}, sys.Reads)
+	assert.Equal(t, []string{"Death"}, sys.Emits)
+
+	for _, input := range []string{`event { number a; }`, `event Damage number a;`, `events Damage {}`} {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParser_Resource(t *testing.T) {
+	input := `resource GameTime {
+		number elapsed = 0;
+		number scale = 1;
+	}
+	system Clock {
+		uses GameTime, Settings
+		params { number dt; }
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	resource, ok := program.Statements[0].(*ast.Resource)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.Resource. got=%T", program.Statements[0])
+	}
+	assert.Equal(t, "resource GameTime { elapsed: number = 0; scale: number = 1; }", resource.String())
+
+	sys := program.Statements[1].(*ast.System)
+	assert.Equal(t, []string{"GameTime", "Settings"}, sys.Uses)
+
+	for _, input := range []string{`resource { number a; }`, `resource GameTime number a;`, `system S { uses }`} {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParser_Prefab(t *testing.T) {
+	input := `prefab Player {
+		CharacterController { walkSpeed = 20, tags = { "hero" }, };
+		CharacterInput;
+		Health { current = 50 * 2 };
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	prefab, ok := program.Statements[0].(*ast.Prefab)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.Prefab. got=%T", program.Statements[0])
+	}
+	assert.Equal(t, `prefab Player { CharacterController { walkSpeed = 20, tags = {"hero"} }; CharacterInput; Health { current = (50 * 2) }; }`, prefab.String())
+
+	program, err = New(`prefab FastPlayer extends Player { CharacterController { walkSpeed = 32 }; }`).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	assert.Equal(t, "Player", program.Statements[0].(*ast.Prefab).Extends)
+	assert.Equal(t, "prefab FastPlayer extends Player { CharacterController { walkSpeed = 32 }; }", program.Statements[0].String())
+
+	for _, input := range []string{
+		`prefab { Health; }`,
+		`prefab FastPlayer extends { Health; }`,
+		`prefab Player { Health }`,
+		`prefab Player { Health { current } ; }`,
+		`prefab Player { Health { current = 1 max = 2 }; }`,
+	} {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParser_Observer(t *testing.T) {
+	input := `observer OnHealthZero {
+		on changed(Health) where Health.current <= 0 {
+			print(entity)
+		}
+	}
+	observer OnSpawn { on added(Health) { } }`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	observer, ok := program.Statements[0].(*ast.Observer)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not *ast.Observer. got=%T", program.Statements[0])
+	}
+	assert.Equal(t, "changed", observer.Trigger)
+	assert.Equal(t, "Health", observer.Component)
+	assert.Equal(t, "observer OnHealthZero { on changed(Health) where (Health.current <= 0) { print(entity) } }", observer.String())
+	assert.Nil(t, program.Statements[1].(*ast.Observer).Where)
+
+	for _, input := range []string{
+		`observer { on added(Health) { } }`,
+		`observer O { added(Health) { } }`,
+		`observer O { on updated(Health) { } }`,
+		`observer O { on added Health { } }`,
+		`observer O { on added(Health) where { } }`,
+		`observer O { on added(Health) }`,
+		`observer O { on added(Health) { }`,
+	} {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParser_QueryWhere(t *testing.T) {
+	input := `system CharacterAnimator {
+		query(CharacterController, CharacterAnimation) where CharacterAnimation.animator != nil && CharacterController.walkSpeed > 0
+		params { number dt; }
+		{ play(characterAnimation) }
+	}`
+	p := New(input)
+	program, err := p.ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	checkParserErrors(t, p)
+
+	sys := program.Statements[0].(*ast.System)
+	assert.Equal(t, "((CharacterAnimation.animator != nil) && (CharacterController.walkSpeed > 0))", sys.Query.Where.String())
+	assert.Len(t, sys.Parameters, 1)
+	assert.Equal(t, "play(characterAnimation)", sys.Code)
+
+	for _, input := range []string{
+		`system S { query(A) where { } }`,
+		`system S { where A.x > 0 { } }`,
+	} {
+		if _, err := New(input).ParseProgram(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+// Package robloxapi describes the Roblox datatypes and enums EJECS schemas can
+// use: their constructors, static values, members and methods, plus the items
+// of every Enum. A curated database is embedded in the binary. A newer API
+// dump can be layered on top with LoadFile, which accepts the Enums section of
+// the official API-Dump.json as well as the DataTypes extension used by the
+// bundled file.
+package robloxapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+//go:embed api.json
+var bundledJSON []byte
+
+// Database is a set of Roblox datatypes and enums
+type Database struct {
+	Version       int    // Dump format version
+	ClientVersion string `json:",omitempty"` // Roblox client the dump was taken from
+	DataTypes     []*DataType
+	Enums         []*Enum
+
+	dataTypes map[string]*DataType
+	enums     map[string]*Enum
+}
+
+// DataType is a Roblox value type such as Vector3 or UDim2. Types are named by
+// their EJECS spelling: number, string, boolean, table, any, EnumItem or
+// another datatype.
+type DataType struct {
+	Name         string
+	Default      string                `json:",omitempty"` // Luau value for fields without a default
+	Constructors map[string][][]string `json:",omitempty"` // Overloads of each static constructor
+	Properties   map[string]string     `json:",omitempty"` // Static values such as Vector3.zero
+	Members      map[string]string     `json:",omitempty"` // Instance properties such as v.Magnitude
+	Methods      map[string]string     `json:",omitempty"` // Return type of each method
+}
+
+// Enum is a Roblox enum such as Enum.KeyCode
+type Enum struct {
+	Name  string
+	Items []EnumItem
+
+	items map[string]bool
+}
+
+// EnumItem is a single item of an Enum. API dumps carry more fields (Value,
+// Tags) which are ignored.
+type EnumItem struct {
+	Name string
+}
+
+// Has reports whether the enum has an item called name
+func (e *Enum) Has(name string) bool {
+	return e.items[name]
+}
+
+// Parse decodes a database in the bundled JSON format
+func Parse(data []byte) (*Database, error) {
+	var db Database
+	if err := json.Unmarshal(data, &db); err != nil {
+		return nil, err
+	}
+	if err := db.index(); err != nil {
+		return nil, err
+	}
+	return &db, nil
+}
+
+// index builds the lookup maps and rejects unnamed or duplicate entries
+func (db *Database) index() error {
+	db.dataTypes = make(map[string]*DataType, len(db.DataTypes))
+	for _, dt := range db.DataTypes {
+		if dt.Name == "" {
+			return fmt.Errorf("datatype without a name")
+		}
+		if _, exists := db.dataTypes[dt.Name]; exists {
+			return fmt.Errorf("datatype %s is declared more than once", dt.Name)
+		}
+		db.dataTypes[dt.Name] = dt
+	}
+	db.enums = make(map[string]*Enum, len(db.Enums))
+	for _, enum := range db.Enums {
+		if enum.Name == "" {
+			return fmt.Errorf("enum without a name")
+		}
+		if _, exists := db.enums[enum.Name]; exists {
+			return fmt.Errorf("enum %s is declared more than once", enum.Name)
+		}
+		enum.items = make(map[string]bool, len(enum.Items))
+		for _, item := range enum.Items {
+			enum.items[item.Name] = true
+		}
+		db.enums[enum.Name] = enum
+	}
+	return nil
+}
+
+// Merge returns a database with the datatypes and enums of other layered over
+// those of db. Entries with the same name are replaced.
+func (db *Database) Merge(other *Database) *Database {
+	merged := &Database{Version: other.Version, ClientVersion: other.ClientVersion}
+	if merged.ClientVersion == "" {
+		merged.ClientVersion = db.ClientVersion
+	}
+	for _, dt := range db.DataTypes {
+		if other.DataType(dt.Name) == nil {
+			merged.DataTypes = append(merged.DataTypes, dt)
+		}
+	}
+	merged.DataTypes = append(merged.DataTypes, other.DataTypes...)
+	for _, enum := range db.Enums {
+		if other.Enum(enum.Name) == nil {
+			merged.Enums = append(merged.Enums, enum)
+		}
+	}
+	merged.Enums = append(merged.Enums, other.Enums...)
+	merged.index() // Both inputs are already indexed, so names are unique
+	return merged
+}
+
+// DataType returns the datatype called name, or nil
+func (db *Database) DataType(name string) *DataType {
+	return db.dataTypes[name]
+}
+
+// IsDataType reports whether name is a known datatype
+func (db *Database) IsDataType(name string) bool {
+	return db.dataTypes[name] != nil
+}
+
+// Enum returns the enum called name (without the Enum. prefix), or nil
+func (db *Database) Enum(name string) *Enum {
+	return db.enums[name]
+}
+
+// Complete returns the names that can follow prefix, for editor completion.
+// "Vec" completes datatypes, "Vector3." the constructors and static values of
+// Vector3, "Enum." enum names and "Enum.KeyCode." the items of KeyCode.
+func (db *Database) Complete(prefix string) []string {
+	var candidates []string
+	dot := strings.LastIndex(prefix, ".")
+	owner, partial := "", prefix
+	if dot >= 0 {
+		owner, partial = prefix[:dot], prefix[dot+1:]
+	}
+
+	switch {
+	case owner == "":
+		for _, dt := range db.DataTypes {
+			candidates = append(candidates, dt.Name)
+		}
+		candidates = append(candidates, "Enum")
+	case owner == "Enum":
+		for _, enum := range db.Enums {
+			candidates = append(candidates, enum.Name)
+		}
+	case strings.HasPrefix(owner, "Enum."):
+		if enum := db.Enum(strings.TrimPrefix(owner, "Enum.")); enum != nil {
+			for _, item := range enum.Items {
+				candidates = append(candidates, item.Name)
+			}
+		}
+	default:
+		if dt := db.DataType(owner); dt != nil {
+			for name := range dt.Constructors {
+				candidates = append(candidates, name)
+			}
+			for name := range dt.Properties {
+				candidates = append(candidates, name)
+			}
+		}
+	}
+
+	var matches []string
+	for _, name := range candidates {
+		if strings.HasPrefix(name, partial) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+var (
+	bundled     *Database
+	bundledOnce sync.Once
+	current     atomic.Pointer[Database]
+)
+
+// Bundled returns the database embedded in the binary
+func Bundled() *Database {
+	bundledOnce.Do(func() {
+		db, err := Parse(bundledJSON)
+		if err != nil {
+			panic(fmt.Sprintf("robloxapi: invalid bundled api.json: %v", err))
+		}
+		bundled = db
+	})
+	return bundled
+}
+
+// LoadFile reads an API dump and layers it over the bundled database
+func LoadFile(path string) (*Database, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return Bundled().Merge(db), nil
+}
+
+// Current returns the database used for validation and code generation. It is
+// the bundled database unless replaced with Use.
+func Current() *Database {
+	if db := current.Load(); db != nil {
+		return db
+	}
+	return Bundled()
+}
+
+// Use makes db the database returned by Current
+func Use(db *Database) {
+	current.Store(db)
+}
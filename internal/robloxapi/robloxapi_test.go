@@ -0,0 +1,108 @@
+package robloxapi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBundled(t *testing.T) {
+	db := Bundled()
+
+	for _, name := range []string{"Vector3", "Region3int16", "Vector2int16", "Font", "PhysicalProperties", "EnumItem"} {
+		if !db.IsDataType(name) {
+			t.Errorf("expected bundled datatype %s", name)
+		}
+	}
+	if db.IsDataType("Region3Int16") {
+		t.Errorf("Region3Int16 should be spelled Region3int16")
+	}
+
+	vector3 := db.DataType("Vector3")
+	if vector3.Default != "Vector3.new(0, 0, 0)" {
+		t.Errorf("Vector3 default wrong. got=%q", vector3.Default)
+	}
+	if len(vector3.Constructors["new"]) != 4 || vector3.Properties["zero"] != "Vector3" || vector3.Methods["Dot"] != "number" {
+		t.Errorf("Vector3 signatures wrong. got=%+v", vector3)
+	}
+
+	keyCode := db.Enum("KeyCode")
+	if keyCode == nil || !keyCode.Has("W") || keyCode.Has("Nope") {
+		t.Errorf("KeyCode items wrong. got=%+v", keyCode)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"DataTypes": [{"Name": ""}]}`, "datatype without a name"},
+		{`{"DataTypes": [{"Name": "A"}, {"Name": "A"}]}`, "datatype A is declared more than once"},
+		{`{"Enums": [{"Name": "E"}, {"Name": "E"}]}`, "enum E is declared more than once"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse([]byte(tt.input)); err == nil || err.Error() != tt.expected {
+			t.Errorf("Parse(%s) error wrong. expected=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	// An API-Dump.json style file: Enums with extra fields, no DataTypes
+	dump := `{
+		"Version": 1,
+		"Classes": [],
+		"Enums": [
+			{"Name": "KeyCode", "Items": [{"Name": "W", "Value": 119}, {"Name": "Brand New", "Value": 9000}]},
+			{"Name": "NewEnum", "Items": [{"Name": "One", "Value": 0}]}
+		]
+	}`
+	path := filepath.Join(t.TempDir(), "API-Dump.json")
+	if err := os.WriteFile(path, []byte(dump), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile error: %v", err)
+	}
+	if !db.Enum("KeyCode").Has("Brand New") || db.Enum("KeyCode").Has("A") {
+		t.Errorf("KeyCode should be replaced by the dump")
+	}
+	if db.Enum("NewEnum") == nil || db.Enum("Material") == nil {
+		t.Errorf("expected enums from both the dump and the bundle")
+	}
+	if !db.IsDataType("Vector3") {
+		t.Errorf("bundled datatypes should be kept")
+	}
+	if db.ClientVersion != Bundled().ClientVersion {
+		t.Errorf("ClientVersion wrong. got=%q", db.ClientVersion)
+	}
+
+	Use(db)
+	defer Use(Bundled())
+	if Current() != db {
+		t.Errorf("Use did not replace the current database")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	db := Bundled()
+	tests := []struct {
+		prefix   string
+		expected []string
+	}{
+		{"Vector3i", []string{"Vector3int16"}},
+		{"Vector3.", []string{"FromAxis", "FromNormalId", "new", "one", "xAxis", "yAxis", "zAxis", "zero"}},
+		{"Enum.Easing", []string{"EasingDirection", "EasingStyle"}},
+		{"Enum.EasingDirection.", []string{"In", "InOut", "Out"}},
+		{"Enum.Missing.", nil},
+	}
+	for _, tt := range tests {
+		if got := db.Complete(tt.prefix); !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Complete(%q) wrong. expected=%v, got=%v", tt.prefix, tt.expected, got)
+		}
+	}
+}
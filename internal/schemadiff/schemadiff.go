@@ -0,0 +1,218 @@
+// Package schemadiff compares two versions of an EJECS schema and classifies
+// every component change as breaking or compatible for data saved with the old
+// version. Changes covered by a migrate block in the new schema are compatible.
+package schemadiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// Change is a single difference between two schemas
+type Change struct {
+	Component string
+	Field     string // Empty for component-level changes
+	Breaking  bool
+	Message   string
+}
+
+func (c Change) String() string {
+	kind := "compatible"
+	if c.Breaking {
+		kind = "BREAKING"
+	}
+	name := c.Component
+	if c.Field != "" {
+		name += "." + c.Field
+	}
+	return fmt.Sprintf("%-10s %s: %s", kind, name, c.Message)
+}
+
+// HasBreaking reports whether any change is breaking
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// Compare returns the changes between the components of old and new, in the
+// order they are declared in new followed by removed components.
+func Compare(old, new *ast.Program) []Change {
+	oldComps := components(old)
+	newComps := components(new)
+
+	var changes []Change
+	for _, comp := range newComps {
+		prev := find(oldComps, comp.Name)
+		if prev == nil {
+			changes = append(changes, Change{Component: comp.Name, Message: "component added"})
+			continue
+		}
+		changes = append(changes, compareComponent(prev, comp)...)
+	}
+	for _, comp := range oldComps {
+		if find(newComps, comp.Name) == nil {
+			changes = append(changes, Change{Component: comp.Name, Breaking: true, Message: "component removed"})
+		}
+	}
+	return changes
+}
+
+// coverage is what the migrate blocks between two versions take care of
+type coverage struct {
+	renames  map[string]string // Old field name -> new field name
+	defaults map[string]bool
+	removed  map[string]bool
+}
+
+// migrationCoverage folds the migrate blocks that upgrade data saved at
+// fromVersion into a single rename/default/remove set.
+func migrationCoverage(comp *ast.Component, fromVersion int) coverage {
+	cov := coverage{renames: map[string]string{}, defaults: map[string]bool{}, removed: map[string]bool{}}
+
+	migrations := make([]*ast.Migration, 0, len(comp.Migrations))
+	for _, m := range comp.Migrations {
+		if m.From >= fromVersion {
+			migrations = append(migrations, m)
+		}
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].From < migrations[j].From })
+
+	// current maps each field's name at the start to its name after the steps so far
+	current := map[string]string{}
+	for _, m := range migrations {
+		for _, step := range m.Steps {
+			switch step.Action {
+			case ast.MigrateRename:
+				original := step.Field
+				for from, to := range current {
+					if to == step.Field {
+						original = from
+					}
+				}
+				current[original] = step.To
+			case ast.MigrateDefault:
+				cov.defaults[step.Field] = true
+			case ast.MigrateRemove:
+				cov.removed[step.Field] = true
+			}
+		}
+	}
+	for from, to := range current {
+		if from != to {
+			cov.renames[from] = to
+		}
+	}
+	return cov
+}
+
+func compareComponent(old, new *ast.Component) []Change {
+	var changes []Change
+	add := func(field string, breaking bool, format string, args ...any) {
+		changes = append(changes, Change{Component: new.Name, Field: field, Breaking: breaking, Message: fmt.Sprintf(format, args...)})
+	}
+
+	oldVersion := max(old.Version, 1)
+	newVersion := max(new.Version, 1)
+	if newVersion < oldVersion {
+		add("", true, "version decreased from %d to %d", oldVersion, newVersion)
+	} else if newVersion > oldVersion {
+		add("", false, "version bumped from %d to %d", oldVersion, newVersion)
+	}
+
+	cov := migrationCoverage(new, oldVersion)
+	matched := map[string]bool{}
+
+	for _, field := range old.Fields {
+		name := field.Name
+		if to, ok := cov.renames[name]; ok {
+			name = to
+		}
+		next := findField(new, name)
+		if next == nil {
+			if cov.removed[field.Name] {
+				add(field.Name, false, "field removed (migrated)")
+			} else {
+				add(field.Name, true, "field removed without a migration")
+			}
+			continue
+		}
+		matched[next.Name] = true
+
+		if name != field.Name {
+			add(field.Name, false, "renamed to %s (migrated)", name)
+		}
+		if oldType, newType := typeString(field), typeString(next); oldType != newType {
+			add(next.Name, true, "type changed from %s to %s", oldType, newType)
+		}
+		if field.Optional && !next.Optional {
+			if cov.defaults[next.Name] {
+				add(next.Name, false, "made required (migration supplies a default)")
+			} else {
+				add(next.Name, true, "made required without a migration default")
+			}
+		} else if !field.Optional && next.Optional {
+			add(next.Name, false, "made optional")
+		}
+	}
+
+	for _, field := range new.Fields {
+		if matched[field.Name] {
+			continue
+		}
+		switch {
+		case field.Optional:
+			add(field.Name, false, "optional field added")
+		case cov.defaults[field.Name]:
+			add(field.Name, false, "field added (migration supplies a default)")
+		case field.DefaultValue != nil:
+			add(field.Name, false, "field added with a default value")
+		default:
+			add(field.Name, true, "required field added without a default")
+		}
+	}
+	return changes
+}
+
+// components returns the components of program that hold data, leaving out
+// generic templates since their instances are compared instead
+func components(program *ast.Program) []*ast.Component {
+	var comps []*ast.Component
+	for _, stmt := range program.Statements {
+		if comp, ok := stmt.(*ast.Component); ok && !comp.IsGeneric() {
+			comps = append(comps, comp)
+		}
+	}
+	return comps
+}
+
+func find(comps []*ast.Component, name string) *ast.Component {
+	for _, comp := range comps {
+		if comp.Name == name {
+			return comp
+		}
+	}
+	return nil
+}
+
+func findField(comp *ast.Component, name string) *ast.Field {
+	for _, field := range comp.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// typeString returns a field's type as written in the schema, ignoring optionality
+func typeString(field *ast.Field) string {
+	if field.Type == "table" && field.MapKeyType != "" {
+		return fmt.Sprintf("table<%s, %s>", field.MapKeyType, field.MapValueType)
+	}
+	return field.Type
+}
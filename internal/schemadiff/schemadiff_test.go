@@ -0,0 +1,104 @@
+package schemadiff
+
+import (
+	"testing"
+
+	"github.com/ejecs/ejecs/internal/ast"
+	"github.com/ejecs/ejecs/internal/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	program, err := parser.New(input).ParseProgram()
+	if err != nil {
+		t.Fatalf("ParseProgram error: %v", err)
+	}
+	return program
+}
+
+func TestCompare(t *testing.T) {
+	old := parse(t, `
+	component Player {
+		string name;
+		int level;
+		number? bonus;
+		boolean legacy;
+		boolean dropped;
+	}
+	component Removed {
+		number x;
+	}`)
+
+	new := parse(t, `
+	@version(2)
+	component Player {
+		string displayName;
+		string level;
+		number bonus;
+		number coins = 5;
+		string? title;
+		string required;
+		migrate from 1 {
+			rename name -> displayName;
+			remove legacy;
+		}
+	}
+	component Added {
+		number y;
+	}`)
+
+	var got []string
+	for _, c := range Compare(old, new) {
+		got = append(got, c.String())
+	}
+
+	expected := []string{
+		"compatible Player: version bumped from 1 to 2",
+		"compatible Player.name: renamed to displayName (migrated)",
+		"BREAKING   Player.level: type changed from int to string",
+		"BREAKING   Player.bonus: made required without a migration default",
+		"compatible Player.legacy: field removed (migrated)",
+		"BREAKING   Player.dropped: field removed without a migration",
+		"compatible Player.coins: field added with a default value",
+		"compatible Player.title: optional field added",
+		"BREAKING   Player.required: required field added without a default",
+		"compatible Added: component added",
+		"BREAKING   Removed: component removed",
+	}
+	assert.Equal(t, expected, got)
+}
+
+func TestCompare_MigrationChain(t *testing.T) {
+	old := parse(t, `
+	@version(2)
+	component Stats {
+		number hp;
+		number? shield;
+	}`)
+
+	new := parse(t, `
+	@version(4)
+	component Stats {
+		number health;
+		number shield;
+		migrate from 1 {
+			remove ancient;
+		}
+		migrate from 2 {
+			rename hp -> hitPoints;
+			default shield = 0;
+		}
+		migrate from 3 {
+			rename hitPoints -> health;
+		}
+	}`)
+
+	changes := Compare(old, new)
+	assert.False(t, HasBreaking(changes), "changes: %v", changes)
+	assert.Contains(t, changes, Change{Component: "Stats", Field: "hp", Message: "renamed to health (migrated)"})
+	assert.Contains(t, changes, Change{Component: "Stats", Field: "shield", Message: "made required (migration supplies a default)"})
+
+	// Identical schemas have no changes
+	assert.Empty(t, Compare(old, old))
+}
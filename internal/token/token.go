@@ -38,6 +38,8 @@ const (
 	PLUSEQ   = "+="
 	AND      = "&&"
 	OR       = "||"
+	ARROW    = "->"
+	CONCAT   = ".."
 
 	// Delimiters
 	COMMA     = ","
@@ -79,9 +81,12 @@ const (
 	CONTINUE     = "continue"
 	NULL         = "null"
 	TABLE        = "table"
+	CONST        = "const"
 )
 
-// Complex types supported by the language
+// Complex types supported by the language. These are the most common
+// Roblox datatypes; the checker looks up the full list in the robloxapi
+// database.
 type ComplexType string
 
 const (
@@ -96,36 +101,13 @@ const (
 	UDim2          ComplexType = "UDim2"
 	Ray            ComplexType = "Ray"
 	Region3        ComplexType = "Region3"
-	Region3Int16   ComplexType = "Region3Int16"
+	Region3int16   ComplexType = "Region3int16"
 	Rect           ComplexType = "Rect"
 	Instance       ComplexType = "Instance"
 	EnumItem       ComplexType = "EnumItem"
 	BrickColor     ComplexType = "BrickColor"
 )
 
-// IsComplexType checks if a string represents a complex type
-func IsComplexType(s string) bool {
-	_, ok := map[string]ComplexType{
-		"Vector2":        Vector2,
-		"Vector3":        Vector3,
-		"CFrame":         CFrame,
-		"Color3":         Color3,
-		"ColorSequence":  ColorSequence,
-		"NumberRange":    NumberRange,
-		"NumberSequence": NumberSequence,
-		"UDim":           UDim,
-		"UDim2":          UDim2,
-		"Ray":            Ray,
-		"Region3":        Region3,
-		"Region3Int16":   Region3Int16,
-		"Rect":           Rect,
-		"Instance":       Instance,
-		"EnumItem":       EnumItem,
-		"BrickColor":     BrickColor,
-	}[s]
-	return ok
-}
-
 // IsKeyword checks if a string is a language keyword
 func IsKeyword(s string) bool {
 	switch s {
@@ -133,7 +115,7 @@ func IsKeyword(s string) bool {
 		"run", "pair", "getTarget", "using", "code",
 		"function", "let", "true", "false", "if",
 		"else", "return", "for", "in", "while",
-		"break", "continue", "null":
+		"break", "continue", "null", "const":
 		return true
 	}
 	return false
@@ -0,0 +1,381 @@
+// Package wire describes the binary layout of replicated components and
+// provides a Go reference encoder for it. The generator emits Luau buffer code
+// from the same Layout, so golden tests against Encode pin the wire format.
+//
+// A serialized component is a little-endian header bitfield followed by the
+// non-boolean fields in declaration order:
+//
+//   - booleans live entirely in the header (one value bit each)
+//   - optional fields get a presence bit and are omitted when nil
+//   - strings are a u16 byte length followed by the bytes
+//
+// A delta starts with a dirty mask (bit i set when field i changed) followed by
+// only the dirty fields. In a delta, booleans are a u8 and optional fields are
+// prefixed with a u8 presence flag.
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+// Kind is the encoding used for a field
+type Kind int
+
+const (
+	Bool    Kind = iota // Header bit only
+	F32                 // float
+	F64                 // number
+	I32                 // int
+	String              // u16 length + bytes
+	Vector2             // 2 x f32
+	Vector3             // 3 x f32
+	CFrame              // position 3 x f32, XYZ euler angles 3 x f32
+	Color3              // 3 x u8
+	UDim                // f32 scale, i32 offset
+	UDim2               // 2 x UDim
+)
+
+// MaxHeaderBits is the number of presence/value bits a component may use
+const MaxHeaderBits = 32
+
+// MaxFields is the number of fields a dirty mask can track
+const MaxFields = 32
+
+var kinds = map[string]Kind{
+	"boolean": Bool,
+	"float":   F32,
+	"number":  F64,
+	"int":     I32,
+	"string":  String,
+	"Vector2": Vector2,
+	"Vector3": Vector3,
+	"CFrame":  CFrame,
+	"Color3":  Color3,
+	"UDim":    UDim,
+	"UDim2":   UDim2,
+}
+
+// KindOf returns the encoding for an EJECS field type
+func KindOf(fieldType string) (Kind, bool) {
+	k, ok := kinds[fieldType]
+	return k, ok
+}
+
+// Size returns the fixed encoded size of a kind in bytes. Strings report the
+// size of their length prefix; booleans take no space outside the header.
+func (k Kind) Size() int {
+	switch k {
+	case F32, I32:
+		return 4
+	case F64, UDim:
+		return 8
+	case String:
+		return 2
+	case Vector2:
+		return 8
+	case Vector3:
+		return 12
+	case CFrame:
+		return 24
+	case Color3:
+		return 3
+	case UDim2:
+		return 16
+	default:
+		return 0
+	}
+}
+
+// Field is a single serialized field
+type Field struct {
+	Name        string
+	Kind        Kind
+	Optional    bool
+	PresenceBit int // Header bit set when an optional field is present, -1 otherwise
+	ValueBit    int // Header bit holding a boolean's value, -1 otherwise
+}
+
+// Layout is the wire layout of one component
+type Layout struct {
+	Component string
+	Fields    []*Field
+	Bits      int // Header bits in use
+}
+
+// NewLayout computes the layout of a component. Fields marked @transient are
+// not replicated.
+func NewLayout(comp *ast.Component) (*Layout, error) {
+	layout := &Layout{Component: comp.Name}
+	for _, f := range comp.Fields {
+		if f.Attribute("transient") != nil {
+			continue
+		}
+		kind, ok := KindOf(f.Type)
+		if !ok {
+			return nil, fmt.Errorf("component %s: field '%s' of type %s cannot be replicated (mark it @transient to skip it)", comp.Name, f.Name, f.Type)
+		}
+		field := &Field{Name: f.Name, Kind: kind, Optional: f.Optional, PresenceBit: -1, ValueBit: -1}
+		if f.Optional {
+			field.PresenceBit = layout.Bits
+			layout.Bits++
+		}
+		if kind == Bool {
+			field.ValueBit = layout.Bits
+			layout.Bits++
+		}
+		layout.Fields = append(layout.Fields, field)
+	}
+	if len(layout.Fields) > MaxFields {
+		return nil, fmt.Errorf("component %s: %d replicated fields exceed the limit of %d", comp.Name, len(layout.Fields), MaxFields)
+	}
+	if layout.Bits > MaxHeaderBits {
+		return nil, fmt.Errorf("component %s: %d boolean/optional fields exceed the limit of %d", comp.Name, layout.Bits, MaxHeaderBits)
+	}
+	return layout, nil
+}
+
+// HeaderSize returns the size of the header bitfield in bytes (0, 1, 2 or 4)
+func (l *Layout) HeaderSize() int {
+	return bitfieldSize(l.Bits)
+}
+
+// MaskSize returns the size of a delta's dirty mask in bytes (1, 2 or 4)
+func (l *Layout) MaskSize() int {
+	if len(l.Fields) == 0 {
+		return 1
+	}
+	return bitfieldSize(len(l.Fields))
+}
+
+// DirtyBit returns the dirty mask bit of the i-th replicated field
+func DirtyBit(i int) uint32 {
+	return 1 << i
+}
+
+func bitfieldSize(bits int) int {
+	switch {
+	case bits == 0:
+		return 0
+	case bits <= 8:
+		return 1
+	case bits <= 16:
+		return 2
+	default:
+		return 4
+	}
+}
+
+// Go-side values accepted by Encode
+type (
+	Vec2Value   struct{ X, Y float32 }
+	Vec3Value   struct{ X, Y, Z float32 }
+	CFrameValue struct{ Position, Rotation Vec3Value }
+	Color3Value struct{ R, G, B float64 }
+	UDimValue   struct {
+		Scale  float32
+		Offset int32
+	}
+	UDim2Value struct{ X, Y UDimValue }
+)
+
+// Encode serializes values (keyed by field name) exactly like the generated
+// Luau serialize function. Missing or nil optional fields are omitted.
+func (l *Layout) Encode(values map[string]any) ([]byte, error) {
+	var header uint32
+	var body []byte
+
+	for _, field := range l.Fields {
+		value, present := values[field.Name]
+		present = present && value != nil
+		if !present {
+			if !field.Optional {
+				return nil, fmt.Errorf("%s: missing required field '%s'", l.Component, field.Name)
+			}
+			continue
+		}
+		if field.Optional {
+			header |= 1 << field.PresenceBit
+		}
+
+		var err error
+		if field.Kind == Bool {
+			b, ok := value.(bool)
+			if !ok {
+				return nil, typeError(l.Component, field, value)
+			}
+			if b {
+				header |= 1 << field.ValueBit
+			}
+			continue
+		}
+		body, err = appendValue(body, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.Component, err)
+		}
+	}
+
+	out := appendBitfield(nil, header, l.HeaderSize())
+	return append(out, body...), nil
+}
+
+// EncodeDelta serializes only the fields selected by mask, exactly like the
+// generated Luau serializeDelta function.
+func (l *Layout) EncodeDelta(mask uint32, values map[string]any) ([]byte, error) {
+	out := appendBitfield(nil, mask, l.MaskSize())
+
+	for i, field := range l.Fields {
+		if mask&DirtyBit(i) == 0 {
+			continue
+		}
+		value, present := values[field.Name]
+		present = present && value != nil
+		if field.Optional {
+			if !present {
+				out = append(out, 0)
+				continue
+			}
+			out = append(out, 1)
+		} else if !present {
+			return nil, fmt.Errorf("%s: missing required field '%s'", l.Component, field.Name)
+		}
+
+		if field.Kind == Bool {
+			b, ok := value.(bool)
+			if !ok {
+				return nil, typeError(l.Component, field, value)
+			}
+			if b {
+				out = append(out, 1)
+			} else {
+				out = append(out, 0)
+			}
+			continue
+		}
+		var err error
+		out, err = appendValue(out, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", l.Component, err)
+		}
+	}
+	return out, nil
+}
+
+func appendBitfield(b []byte, bits uint32, size int) []byte {
+	switch size {
+	case 1:
+		return append(b, byte(bits))
+	case 2:
+		return binary.LittleEndian.AppendUint16(b, uint16(bits))
+	case 4:
+		return binary.LittleEndian.AppendUint32(b, bits)
+	}
+	return b
+}
+
+func appendValue(b []byte, field *Field, value any) ([]byte, error) {
+	le := binary.LittleEndian
+	switch field.Kind {
+	case F32, F64, I32:
+		n, ok := toFloat(value)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		switch field.Kind {
+		case F32:
+			return le.AppendUint32(b, math.Float32bits(float32(n))), nil
+		case F64:
+			return le.AppendUint64(b, math.Float64bits(n)), nil
+		default:
+			return le.AppendUint32(b, uint32(int32(n))), nil
+		}
+	case String:
+		s, ok := value.(string)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		if len(s) > math.MaxUint16 {
+			return nil, fmt.Errorf("field '%s': string of %d bytes exceeds %d", field.Name, len(s), math.MaxUint16)
+		}
+		b = le.AppendUint16(b, uint16(len(s)))
+		return append(b, s...), nil
+	case Vector2:
+		v, ok := value.(Vec2Value)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		return appendF32(b, v.X, v.Y), nil
+	case Vector3:
+		v, ok := value.(Vec3Value)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		return appendF32(b, v.X, v.Y, v.Z), nil
+	case CFrame:
+		v, ok := value.(CFrameValue)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		return appendF32(b, v.Position.X, v.Position.Y, v.Position.Z, v.Rotation.X, v.Rotation.Y, v.Rotation.Z), nil
+	case Color3:
+		v, ok := value.(Color3Value)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		return append(b, colorByte(v.R), colorByte(v.G), colorByte(v.B)), nil
+	case UDim:
+		v, ok := value.(UDimValue)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		return appendUDim(b, v), nil
+	case UDim2:
+		v, ok := value.(UDim2Value)
+		if !ok {
+			return nil, typeError("", field, value)
+		}
+		return appendUDim(appendUDim(b, v.X), v.Y), nil
+	}
+	return nil, fmt.Errorf("field '%s': unsupported kind %d", field.Name, field.Kind)
+}
+
+func appendF32(b []byte, values ...float32) []byte {
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint32(b, math.Float32bits(v))
+	}
+	return b
+}
+
+func appendUDim(b []byte, v UDimValue) []byte {
+	b = appendF32(b, v.Scale)
+	return binary.LittleEndian.AppendUint32(b, uint32(v.Offset))
+}
+
+// colorByte mirrors math.clamp(math.round(c * 255), 0, 255) in Luau
+func colorByte(c float64) byte {
+	return byte(math.Max(0, math.Min(255, math.Round(c*255))))
+}
+
+func toFloat(value any) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func typeError(component string, field *Field, value any) error {
+	if component != "" {
+		return fmt.Errorf("%s: field '%s': unexpected value of type %T", component, field.Name, value)
+	}
+	return fmt.Errorf("field '%s': unexpected value of type %T", field.Name, value)
+}
@@ -0,0 +1,150 @@
+package wire
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ejecs/ejecs/internal/ast"
+)
+
+func testComponent() *ast.Component {
+	return &ast.Component{
+		Name: "Player",
+		Fields: []*ast.Field{
+			{Name: "alive", Type: "boolean"},
+			{Name: "name", Type: "string"},
+			{Name: "level", Type: "int"},
+			{Name: "pos", Type: "Vector3", Optional: true},
+			{Name: "tint", Type: "Color3"},
+			{Name: "model", Type: "Instance", Attributes: []*ast.Attribute{{Name: "transient"}}},
+		},
+	}
+}
+
+func TestNewLayout(t *testing.T) {
+	layout, err := NewLayout(testComponent())
+	if err != nil {
+		t.Fatalf("NewLayout() error = %v", err)
+	}
+	if len(layout.Fields) != 5 {
+		t.Fatalf("expected transient field to be skipped, got %d fields", len(layout.Fields))
+	}
+	if layout.Bits != 2 || layout.HeaderSize() != 1 {
+		t.Errorf("header wrong. bits=%d size=%d", layout.Bits, layout.HeaderSize())
+	}
+	if layout.Fields[0].ValueBit != 0 || layout.Fields[3].PresenceBit != 1 {
+		t.Errorf("bit assignment wrong. alive=%d pos=%d", layout.Fields[0].ValueBit, layout.Fields[3].PresenceBit)
+	}
+
+	bad := &ast.Component{Name: "Bad", Fields: []*ast.Field{{Name: "model", Type: "Instance"}}}
+	if _, err := NewLayout(bad); err == nil {
+		t.Errorf("expected error for Instance field")
+	}
+}
+
+func TestEncode_Golden(t *testing.T) {
+	layout, err := NewLayout(testComponent())
+	if err != nil {
+		t.Fatalf("NewLayout() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]any
+		expected string
+	}{
+		{
+			name: "all fields present",
+			values: map[string]any{
+				"alive": true,
+				"name":  "ab",
+				"level": -2,
+				"pos":   Vec3Value{X: 1, Y: 2, Z: 3},
+				"tint":  Color3Value{R: 1, G: 0.5, B: 0},
+			},
+			expected: "03" + "02006162" + "feffffff" + "0000803f" + "00000040" + "00004040" + "ff8000",
+		},
+		{
+			name: "optional field omitted",
+			values: map[string]any{
+				"alive": false,
+				"name":  "",
+				"level": 7,
+				"tint":  Color3Value{R: 0, G: 0, B: 1},
+			},
+			expected: "00" + "0000" + "07000000" + "0000ff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := layout.Encode(tt.values)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.expected {
+				t.Errorf("Encode() wrong.\nexpected=%s\ngot=%s", tt.expected, hex.EncodeToString(got))
+			}
+		})
+	}
+
+	if _, err := layout.Encode(map[string]any{"alive": true}); err == nil {
+		t.Errorf("expected error for missing required field")
+	}
+}
+
+func TestEncodeDelta_Golden(t *testing.T) {
+	layout, err := NewLayout(testComponent())
+	if err != nil {
+		t.Fatalf("NewLayout() error = %v", err)
+	}
+	if layout.MaskSize() != 1 {
+		t.Fatalf("expected 1 byte mask, got %d", layout.MaskSize())
+	}
+
+	tests := []struct {
+		name     string
+		mask     uint32
+		values   map[string]any
+		expected string
+	}{
+		{
+			name: "boolean, int and present optional",
+			mask: DirtyBit(0) | DirtyBit(2) | DirtyBit(3),
+			values: map[string]any{
+				"alive": true,
+				"level": -2,
+				"pos":   Vec3Value{X: 1, Y: 2, Z: 3},
+			},
+			expected: "0d" + "01" + "feffffff" + "01" + "0000803f" + "00000040" + "00004040",
+		},
+		{
+			name:     "optional cleared",
+			mask:     DirtyBit(3),
+			values:   map[string]any{},
+			expected: "08" + "00",
+		},
+		{
+			name:     "clean fields are not encoded",
+			mask:     0,
+			values:   map[string]any{"name": "ignored"},
+			expected: "00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := layout.EncodeDelta(tt.mask, tt.values)
+			if err != nil {
+				t.Fatalf("EncodeDelta() error = %v", err)
+			}
+			if hex.EncodeToString(got) != tt.expected {
+				t.Errorf("EncodeDelta() wrong.\nexpected=%s\ngot=%s", tt.expected, hex.EncodeToString(got))
+			}
+		})
+	}
+
+	if _, err := layout.EncodeDelta(DirtyBit(1), map[string]any{}); err == nil {
+		t.Errorf("expected error for missing dirty required field")
+	}
+}